@@ -19,6 +19,8 @@ type HaargosClient struct {
 	AgentToken     string
 	Logger         *logrus.Logger
 	OnDataSentInKb func(int)
+	// HTTPClient sends the requests, a default client is used when it is nil.
+	HTTPClient *http.Client
 }
 
 type AgentConfigResponse struct {
@@ -27,6 +29,8 @@ type AgentConfigResponse struct {
 
 type AgentConfig struct {
 	CycleInterval int `json:"cycle_interval"`
+	// InstallationID identifies the installation the agent token belongs to.
+	InstallationID string `json:"installation_id"`
 }
 
 func NewClient(apiURL string, agentToken string, dataSentInKb func(int)) *HaargosClient {
@@ -38,6 +42,13 @@ func NewClient(apiURL string, agentToken string, dataSentInKb func(int)) *Haargo
 	}
 }
 
+// WithTransport returns a copy of the client sending its requests through the transport.
+func (c *HaargosClient) WithTransport(transport http.RoundTripper) *HaargosClient {
+	copy := *c
+	copy.HTTPClient = &http.Client{Transport: transport}
+	return &copy
+}
+
 func (c *HaargosClient) sendRequest(method, url string, data interface{}, headers map[string]string) (*http.Response, error) {
 	jsonData, err := json.Marshal(data)
 	if err != nil {
@@ -83,7 +94,10 @@ func (c *HaargosClient) sendRequest(method, url string, data interface{}, header
 
 	req.Header.Set("x-agent-token", c.AgentToken)
 
-	client := &http.Client{}
+	client := c.HTTPClient
+	if client == nil {
+		client = &http.Client{}
+	}
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -265,6 +279,44 @@ func (c *HaargosClient) FetchOS(headers map[string]string) (*types.OSInfo, error
 	return &response.Data, nil
 }
 
+func (c *HaargosClient) FetchSupervisorJobs(headers map[string]string) (*[]types.SupervisorJob, error) {
+	resp, err := c.sendRequest("GET", "jobs/info", nil, headers)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("received non-OK response status: %s", resp.Status)
+	}
+
+	var response types.SupervisorJobsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("error unmarshaling response: %v", err)
+	}
+
+	return &response.Data.Jobs, nil
+}
+
+func (c *HaargosClient) FetchUpdateInfo(path string, headers map[string]string) (*types.UpdateInfo, error) {
+	resp, err := c.sendRequest("GET", path, nil, headers)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("received non-OK response status: %s", resp.Status)
+	}
+
+	var response types.UpdateInfoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("error unmarshaling response: %v", err)
+	}
+
+	return &response.Data, nil
+}
+
 func (c *HaargosClient) UpdateCore(headers map[string]string) (*http.Response, error) {
 	resp, err := c.sendRequest("POST", "core/update", nil, headers)
 	if err != nil {
@@ -307,6 +359,61 @@ func (c *HaargosClient) GenericPOST(headers map[string]string, path string) (*ht
 	return resp, nil
 }
 
+func (c *HaargosClient) GenericDELETE(headers map[string]string, path string) (*http.Response, error) {
+	resp, err := c.sendRequest("DELETE", path, nil, headers)
+	if err != nil {
+		return resp, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp, fmt.Errorf("received non-OK response status: %s", resp.Status)
+	}
+
+	return resp, nil
+}
+
+// GetJSON decodes the JSON response of a GET request into target.
+func (c *HaargosClient) GetJSON(headers map[string]string, path string, target interface{}) (*http.Response, error) {
+	resp, err := c.sendRequest("GET", path, nil, headers)
+	if err != nil {
+		return resp, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp, fmt.Errorf("received non-OK response status: %s", resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+		return resp, fmt.Errorf("error unmarshaling response: %v", err)
+	}
+
+	return resp, nil
+}
+
+// PostJSON posts the payload and decodes the JSON response into target. The response
+// is decoded for non-OK statuses as well, as they usually carry an error message.
+func (c *HaargosClient) PostJSON(headers map[string]string, path string, payload interface{}, target interface{}) (*http.Response, error) {
+	resp, err := c.sendRequest("POST", path, payload, headers)
+	if err != nil {
+		return resp, err
+	}
+	defer resp.Body.Close()
+
+	decodeErr := json.NewDecoder(resp.Body).Decode(target)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp, fmt.Errorf("received non-OK response status: %s", resp.Status)
+	}
+
+	if decodeErr != nil {
+		return resp, fmt.Errorf("error unmarshaling response: %v", decodeErr)
+	}
+
+	return resp, nil
+}
+
 func (c *HaargosClient) UpdateOS(headers map[string]string) (*http.Response, error) {
 	resp, err := c.sendRequest("POST", "os/update", nil, headers)
 	if err != nil {
@@ -321,11 +428,12 @@ func (c *HaargosClient) UpdateOS(headers map[string]string) (*http.Response, err
 	return resp, nil
 }
 
-func (c *HaargosClient) CompleteJob(job types.GenericJob) error {
-	resp, err := c.sendRequest("POST", fmt.Sprintf("installations/jobs/%s/complete", job.ID), nil, nil)
+func (c *HaargosClient) CompleteJob(job types.GenericJob, result types.JobResult) error {
+	resp, err := c.sendRequest("POST", fmt.Sprintf("installations/jobs/%s/complete", job.ID), result, nil)
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		return fmt.Errorf("received non-OK response status: %s", resp.Status)
@@ -334,6 +442,10 @@ func (c *HaargosClient) CompleteJob(job types.GenericJob) error {
 	return nil
 }
 
+func (c *HaargosClient) SendJobProgress(job types.GenericJob, progress types.JobProgress) (*http.Response, error) {
+	return c.sendRequest("PUT", fmt.Sprintf("installations/jobs/%s/progress", job.ID), progress, make(map[string]string))
+}
+
 func (c *HaargosClient) FetchJobs() (*[]types.GenericJob, error) {
 	resp, err := c.sendRequest("GET", "installations/jobs/pending", nil, nil)
 	if err != nil {
@@ -346,7 +458,10 @@ func (c *HaargosClient) FetchJobs() (*[]types.GenericJob, error) {
 	}
 
 	var response types.JobsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+	decoder := json.NewDecoder(resp.Body)
+	// Keep the numbers of job contexts as they were signed.
+	decoder.UseNumber()
+	if err := decoder.Decode(&response); err != nil {
 		return nil, fmt.Errorf("error unmarshaling response: %v", err)
 	}
 
@@ -32,4 +32,40 @@ if bashio::config.true 'debug_mode'; then
     bashio::log.info "Debug mode is enabled."
 fi
 
-STAGE="${XSTAGE}" DEBUG="${XDEBUG}" HA_ACCESS_TOKEN="${ha_access_token}" HAARGOS_AGENT_TOKEN="${agent_token}" ./haargos run --agent-type addon --zha-path "${HA_CONFIG}zigbee.db" --ha-config "${HA_CONFIG}"
+XDESTRUCTIVE="false"
+if bashio::config.true 'allow_destructive_jobs'; then
+    XDESTRUCTIVE="true"
+    bashio::log.info "Destructive jobs are enabled."
+fi
+
+XPOLICY=()
+if bashio::config.has_value 'allowed_jobs'; then
+    XPOLICY+=(--allowed-jobs "$(bashio::config 'allowed_jobs' | paste -sd, -)")
+    bashio::log.info "Jobs are restricted to the allowed job types."
+fi
+if bashio::config.has_value 'allowed_addons'; then
+    XPOLICY+=(--allowed-addons "$(bashio::config 'allowed_addons' | paste -sd, -)")
+    bashio::log.info "Add-on jobs are restricted to the allowed add-ons."
+fi
+if bashio::config.has_value 'allowed_services'; then
+    XPOLICY+=(--allowed-services "$(bashio::config 'allowed_services' | paste -sd, -)")
+else
+    bashio::log.info "No services are allowed, call_service jobs will be rejected."
+fi
+
+XJOBPUBLICKEY=""
+if bashio::config.has_value 'job_public_key'; then
+    XJOBPUBLICKEY=$(bashio::config 'job_public_key')
+    bashio::log.info "Job signatures will be verified."
+fi
+
+XSCHEDULE=()
+if bashio::config.has_value 'maintenance_window'; then
+    XSCHEDULE+=(--maintenance-window "$(bashio::config 'maintenance_window')")
+    bashio::log.info "Maintenance jobs are restricted to $(bashio::config 'maintenance_window')."
+fi
+if bashio::config.has_value 'maintenance_jobs'; then
+    XSCHEDULE+=(--maintenance-jobs "$(bashio::config 'maintenance_jobs' | paste -sd, -)")
+fi
+
+STAGE="${XSTAGE}" DEBUG="${XDEBUG}" HA_ACCESS_TOKEN="${ha_access_token}" HAARGOS_AGENT_TOKEN="${agent_token}" HAARGOS_JOB_PUBLIC_KEY="${XJOBPUBLICKEY}" ./haargos run --agent-type addon --zha-path "${HA_CONFIG}zigbee.db" --ha-config "${HA_CONFIG}" --data-path "/data/" --allow-destructive-jobs="${XDESTRUCTIVE}" "${XPOLICY[@]}" "${XSCHEDULE[@]}"
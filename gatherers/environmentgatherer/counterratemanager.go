@@ -0,0 +1,60 @@
+package environmentgatherer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// CounterRateManager computes per second rates of groups of counters, e.g. the statistics
+// of each network interface.
+type CounterRateManager struct {
+	Logger         *logrus.Logger
+	lastSample     map[string]map[string]uint64
+	lastSampleTime time.Time
+	mutex          sync.Mutex
+}
+
+func NewCounterRateManager(logger *logrus.Logger) *CounterRateManager {
+	return &CounterRateManager{
+		Logger:     logger,
+		lastSample: map[string]map[string]uint64{},
+	}
+}
+
+// Measure stores the counters and returns their rates since the previous measurement,
+// leaving out groups seen for the first time and counters which were reset.
+func (n *CounterRateManager) Measure(statistics map[string]map[string]uint64) map[string]map[string]float64 {
+	now := time.Now()
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	elapsed := now.Sub(n.lastSampleTime).Seconds()
+	rates := map[string]map[string]float64{}
+
+	for group, counters := range statistics {
+		previous, found := n.lastSample[group]
+		if !found || elapsed <= 0 {
+			continue
+		}
+
+		rates[group] = counterRates(previous, counters, elapsed)
+	}
+
+	n.lastSample = statistics
+	n.lastSampleTime = now
+
+	return rates
+}
+
+func counterRates(previous map[string]uint64, current map[string]uint64, seconds float64) map[string]float64 {
+	rates := map[string]float64{}
+	for name, value := range current {
+		if previousValue, found := previous[name]; found && value >= previousValue {
+			rates[name] = float64(value-previousValue) / seconds
+		}
+	}
+	return rates
+}
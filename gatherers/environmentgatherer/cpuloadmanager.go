@@ -1,94 +1,101 @@
 package environmentgatherer
 
 import (
-	"strconv"
-	"strings"
 	"sync"
-	"time"
 
-	"github.com/evilmint/haargos-agent-golang/repositories/commandrepository"
+	"github.com/evilmint/haargos-agent-golang/repositories/procfsrepository"
+	"github.com/evilmint/haargos-agent-golang/types"
 	"github.com/sirupsen/logrus"
 )
 
+// CPULoadManager computes the CPU utilisation from the /proc/stat counters. Every
+// measurement covers the time since the previous one, i.e. the whole observation cycle.
 type CPULoadManager struct {
-	Logger       *logrus.Logger
-	lastCPULoad  float64
-	commandRepo  *commandrepository.CommandRepository
-	stopFetching chan bool
-	isFetching   bool
-	mutex        sync.Mutex
+	Logger           *logrus.Logger
+	systemRepository SystemRepository
+	lastSample       *procfsrepository.CPUStat
+	mutex            sync.Mutex
 }
 
-func NewCPULoadManager(logger *logrus.Logger, commandRepo *commandrepository.CommandRepository) *CPULoadManager {
+type cpuMeasurement struct {
+	Load      float64
+	Usage     types.CPUUsage
+	CoreLoads []float64
+}
+
+func NewCPULoadManager(logger *logrus.Logger, systemRepository SystemRepository) *CPULoadManager {
 	manager := &CPULoadManager{
-		Logger:       logger,
-		commandRepo:  commandRepo,
-		stopFetching: make(chan bool),
+		Logger:           logger,
+		systemRepository: systemRepository,
+	}
+
+	// Take the first sample right away, so the first measurement covers the time since the agent started.
+	if stat, err := systemRepository.GetCPUStat(); err == nil {
+		manager.lastSample = stat
 	}
+
 	return manager
 }
 
-func (c *CPULoadManager) Start() {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-
-	if !c.isFetching {
-		c.isFetching = true
-		go c.fetchPeriodically()
+// Measure returns the utilisation since the previous measurement. The first measurement
+// without a previous sample covers the time since boot.
+func (c *CPULoadManager) Measure() (*cpuMeasurement, error) {
+	stat, err := c.systemRepository.GetCPUStat()
+	if err != nil {
+		return nil, err
 	}
-}
 
-func (c *CPULoadManager) Stop() {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	if c.isFetching {
-		select {
-		case c.stopFetching <- true:
-		default:
-			c.Logger.Error("Fetcher wasn't actively listening")
-		}
-		c.isFetching = false
+	previous := c.lastSample
+	if previous == nil {
+		previous = &procfsrepository.CPUStat{Cores: make([]procfsrepository.CPUTimes, len(stat.Cores))}
 	}
-}
+	c.lastSample = stat
 
-func (c *CPULoadManager) fetchPeriodically() {
-	time.Sleep(time.Second)
+	usage, ok := cpuUsage(previous.Total, stat.Total)
+	if !ok {
+		// No time passed since the previous sample, e.g. when measured twice in a row.
+		usage, _ = cpuUsage(procfsrepository.CPUTimes{}, stat.Total)
+	}
 
-	ticker := time.NewTicker(2 * time.Minute)
-	defer ticker.Stop()
+	measurement := &cpuMeasurement{
+		Load:      100 - usage.Idle - usage.IOWait,
+		Usage:     usage,
+		CoreLoads: []float64{},
+	}
 
-	for {
-		select {
-		case <-ticker.C:
-			c.fetchCPULoad()
-		case <-c.stopFetching:
-			return
+	// Cores going offline or online change the layout, leave them out until the next cycle.
+	if len(previous.Cores) == len(stat.Cores) {
+		for i := range stat.Cores {
+			coreUsage, _ := cpuUsage(previous.Cores[i], stat.Cores[i])
+			measurement.CoreLoads = append(measurement.CoreLoads, 100-coreUsage.Idle-coreUsage.IOWait)
 		}
 	}
+
+	return measurement, nil
 }
 
-func (c *CPULoadManager) fetchCPULoad() {
-	top, err := c.commandRepo.GetCPULoad()
-	if err != nil {
-		c.Logger.Errorf("Error fetching CPU load: %v", err)
-		return
+// cpuUsage returns the percentage of time spent in each state between the two samples.
+func cpuUsage(previous procfsrepository.CPUTimes, current procfsrepository.CPUTimes) (types.CPUUsage, bool) {
+	if current.Total() <= previous.Total() {
+		return types.CPUUsage{Idle: 100}, false
 	}
 
-	load, err := strconv.ParseFloat(strings.TrimSpace(*top), 64)
-	if err != nil {
-		c.Logger.Errorf("Error parsing CPU load: %v", err)
-		return
+	total := float64(current.Total() - previous.Total())
+	percentage := func(previous uint64, current uint64) float64 {
+		if current < previous {
+			return 0
+		}
+		return float64(current-previous) * 100 / total
 	}
 
-	c.mutex.Lock()
-	c.lastCPULoad = load
-	c.mutex.Unlock()
-}
-
-func (c *CPULoadManager) GetLastCPULoad() float64 {
-	c.mutex.Lock()
-	load := c.lastCPULoad
-	c.mutex.Unlock()
-	return load
+	return types.CPUUsage{
+		User:   percentage(previous.User+previous.Nice, current.User+current.Nice),
+		System: percentage(previous.System+previous.IRQ+previous.SoftIRQ, current.System+current.IRQ+current.SoftIRQ),
+		IOWait: percentage(previous.IOWait, current.IOWait),
+		Steal:  percentage(previous.Steal, current.Steal),
+		Idle:   percentage(previous.Idle, current.Idle),
+	}, true
 }
@@ -0,0 +1,104 @@
+package environmentgatherer
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/evilmint/haargos-agent-golang/repositories/procfsrepository"
+	"github.com/evilmint/haargos-agent-golang/types"
+	"github.com/sirupsen/logrus"
+)
+
+// DiskIOManager computes disk I/O rates from the /proc/diskstats counters. Like the
+// CPU load, every measurement covers the time since the previous one.
+type DiskIOManager struct {
+	Logger           *logrus.Logger
+	systemRepository SystemRepository
+	lastSample       map[string]procfsrepository.DiskStat
+	lastSampleTime   time.Time
+	mutex            sync.Mutex
+}
+
+type diskMeasurement struct {
+	Stat  procfsrepository.DiskStat
+	Rates *types.DiskRates
+}
+
+func NewDiskIOManager(logger *logrus.Logger, systemRepository SystemRepository) *DiskIOManager {
+	manager := &DiskIOManager{
+		Logger:           logger,
+		systemRepository: systemRepository,
+	}
+
+	if stats, err := systemRepository.GetDiskStats(); err == nil {
+		manager.lastSample = diskStatsByName(stats)
+		manager.lastSampleTime = time.Now()
+	}
+
+	return manager
+}
+
+// Measure returns the counters of every block device together with their rates since the previous measurement.
+func (d *DiskIOManager) Measure() (map[string]diskMeasurement, error) {
+	stats, err := d.systemRepository.GetDiskStats()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	elapsed := now.Sub(d.lastSampleTime)
+	measurements := map[string]diskMeasurement{}
+
+	for _, stat := range stats {
+		measurement := diskMeasurement{Stat: stat}
+		if previous, found := d.lastSample[stat.Name]; found {
+			if rates, ok := diskRates(previous, stat, elapsed); ok {
+				measurement.Rates = &rates
+			}
+		}
+		measurements[stat.Name] = measurement
+	}
+
+	d.lastSample = diskStatsByName(stats)
+	d.lastSampleTime = now
+
+	return measurements, nil
+}
+
+// diskRates returns the per second rates between the two samples. It fails when the
+// counters went backwards, e.g. because the device was replaced by another one.
+func diskRates(previous procfsrepository.DiskStat, current procfsrepository.DiskStat, elapsed time.Duration) (types.DiskRates, bool) {
+	if elapsed <= 0 ||
+		current.ReadsCompleted < previous.ReadsCompleted ||
+		current.WritesCompleted < previous.WritesCompleted ||
+		current.SectorsRead < previous.SectorsRead ||
+		current.SectorsWritten < previous.SectorsWritten ||
+		current.IOTime < previous.IOTime ||
+		current.WeightedIOTime < previous.WeightedIOTime {
+		return types.DiskRates{}, false
+	}
+
+	seconds := elapsed.Seconds()
+	milliseconds := float64(elapsed) / float64(time.Millisecond)
+
+	return types.DiskRates{
+		ReadsPerSecond:        float64(current.ReadsCompleted-previous.ReadsCompleted) / seconds,
+		WritesPerSecond:       float64(current.WritesCompleted-previous.WritesCompleted) / seconds,
+		BytesReadPerSecond:    float64(current.BytesRead()-previous.BytesRead()) / seconds,
+		BytesWrittenPerSecond: float64(current.BytesWritten()-previous.BytesWritten()) / seconds,
+		Utilization:           math.Min(float64(current.IOTime-previous.IOTime)*100/milliseconds, 100),
+		QueueDepth:            float64(current.WeightedIOTime-previous.WeightedIOTime) / milliseconds,
+	}, true
+}
+
+func diskStatsByName(stats []procfsrepository.DiskStat) map[string]procfsrepository.DiskStat {
+	byName := map[string]procfsrepository.DiskStat{}
+	for _, stat := range stats {
+		byName[stat.Name] = stat
+	}
+	return byName
+}
@@ -3,155 +3,337 @@ package environmentgatherer
 import (
 	"errors"
 	"fmt"
-	"strconv"
+	"math"
 	"strings"
+	"time"
 
-	"github.com/evilmint/haargos-agent-golang/repositories/commandrepository"
+	"github.com/evilmint/haargos-agent-golang/repositories/procfsrepository"
 	"github.com/evilmint/haargos-agent-golang/types"
 	"github.com/sirupsen/logrus"
 )
 
+// SystemRepository provides the raw system information the environment is gathered from.
+type SystemRepository interface {
+	GetCPUInfo() (*procfsrepository.CPUInfo, error)
+	GetCPUStat() (*procfsrepository.CPUStat, error)
+	GetLoadAverage() (*procfsrepository.LoadAverage, error)
+	GetTemperatureSensors() ([]procfsrepository.TemperatureSensor, error)
+	GetMemInfo() (map[string]uint64, error)
+	GetUptime() (time.Duration, error)
+	GetMounts() ([]procfsrepository.Mount, error)
+	GetDiskStats() ([]procfsrepository.DiskStat, error)
+	GetBlockDevices() ([]procfsrepository.BlockDevice, error)
+	GetDiskHealth(name string) (*procfsrepository.DiskHealth, bool)
+	GetMountInfo() ([]procfsrepository.MountInfo, error)
+	GetFileSystemErrors(mount procfsrepository.MountInfo) (*procfsrepository.FileSystemErrors, error)
+	Statfs(mountPoint string) (*procfsrepository.FSStat, error)
+	GetNetworkInterfaces() ([]string, error)
+	GetInterfaceStatistics(interfaceName string) (map[string]uint64, error)
+	GetInterfaceInfo(interfaceName string) (*procfsrepository.InterfaceInfo, error)
+	GetInterfaceAddresses(interfaceName string) ([]string, error)
+	GetDefaultRouteInterface() (string, error)
+	GetProcesses() ([]procfsrepository.Process, error)
+	GetUserNames() (map[int]string, error)
+	GetPressure(resource string) (*procfsrepository.Pressure, error)
+	GetVMStat() (map[string]uint64, error)
+	GetZramDevices() ([]procfsrepository.ZramDevice, error)
+	GetZswapParameters() (*procfsrepository.ZswapParameters, error)
+}
+
 type EnvironmentGatherer struct {
-	Logger            *logrus.Logger
-	commandRepository *commandrepository.CommandRepository
-	cpuLoadManager    *CPULoadManager
+	Logger             *logrus.Logger
+	systemRepository   SystemRepository
+	cpuLoadManager     *CPULoadManager
+	diskIOManager      *DiskIOManager
+	networkRateManager *CounterRateManager
+	vmStatRateManager  *CounterRateManager
+	processManager     *ProcessManager
+	storageFilter      StorageFilter
+	sampler            *EnvironmentSampler
 }
 
-func NewEnvironmentGatherer(logger *logrus.Logger, commandRepo *commandrepository.CommandRepository) *EnvironmentGatherer {
+func NewEnvironmentGatherer(logger *logrus.Logger, systemRepository SystemRepository) *EnvironmentGatherer {
 	gatherer := &EnvironmentGatherer{
-		Logger:            logger,
-		commandRepository: commandRepo,
-		cpuLoadManager:    NewCPULoadManager(logger, commandRepo),
+		Logger:             logger,
+		systemRepository:   systemRepository,
+		cpuLoadManager:     NewCPULoadManager(logger, systemRepository),
+		diskIOManager:      NewDiskIOManager(logger, systemRepository),
+		networkRateManager: NewCounterRateManager(logger),
+		vmStatRateManager:  NewCounterRateManager(logger),
+		processManager:     NewProcessManager(logger, systemRepository),
 	}
 
 	return gatherer
 }
 
+// SetStorageFilter limits the filesystems reported as storage, all are reported by default.
+func (e *EnvironmentGatherer) SetStorageFilter(storageFilter StorageFilter) {
+	e.storageFilter = storageFilter
+}
+
+// StartSampler samples the environment every interval in the background, keeping up to
+// capacity samples. Their statistics are reported from then on.
+func (e *EnvironmentGatherer) StartSampler(interval time.Duration, capacity int) {
+	e.sampler = NewEnvironmentSampler(e.Logger, e.systemRepository, interval, capacity)
+	e.sampler.Start()
+}
+
 func (e *EnvironmentGatherer) getMemoryInfo() (*types.Memory, error) {
-	out, err := e.commandRepository.GetMemory()
+	memInfo, err := e.systemRepository.GetMemInfo()
 	if err != nil {
 		return nil, fmt.Errorf("Error getting memory info: %v", err)
 	}
 
-	lines := strings.Split(strings.TrimSpace(*out), "\n")
-	if len(lines) < 2 {
-		return nil, errors.New("Failed to parse memory info: insufficient data")
+	total, found := memInfo["MemTotal"]
+	if !found {
+		return nil, errors.New("Failed to parse memory info: MemTotal missing")
 	}
 
-	// Parsing RAM info
-	memInfo := strings.Fields(lines[1])
-	if len(memInfo) < 7 {
-		return nil, errors.New("Failed to parse memory info: unexpected format")
-	}
+	free := memInfo["MemFree"]
+	buffCache := memInfo["Buffers"] + memInfo["Cached"] + memInfo["SReclaimable"]
 
-	memory := &types.Memory{}
-	fields := []*int{&memory.Total, &memory.Used, &memory.Free, &memory.Shared, &memory.BuffCache, &memory.Available}
-	for i, field := range fields {
-		if *field, err = strconv.Atoi(memInfo[i+1]); err != nil {
-			return nil, fmt.Errorf("Failed to parse memory info at field %d: %v", i+1, err)
-		}
+	available, found := memInfo["MemAvailable"]
+	if !found {
+		// Kernels before 3.14 do not estimate the available memory.
+		available = free + buffCache
 	}
 
-	// Parsing SWAP info
-	swapInfo := strings.Fields(lines[2])
-	if len(swapInfo) >= 3 {
-		memory.SwapTotal, err = strconv.Atoi(swapInfo[1])
-		if err != nil {
-			e.Logger.Errorf("Failed to parse swap total: %v", err)
-		}
-		memory.SwapUsed, err = strconv.Atoi(swapInfo[2])
-		if err != nil {
-			e.Logger.Errorf("Failed to parse swap used: %v", err)
-		}
+	// Computed the way free from procps-ng 4 does.
+	used := int64(total) - int64(available)
+	if used < 0 {
+		used = int64(total) - int64(free)
 	}
 
-	return memory, nil
+	return &types.Memory{
+		Total:     int(total),
+		Used:      int(used),
+		Free:      int(free),
+		Shared:    int(memInfo["Shmem"]),
+		BuffCache: int(buffCache),
+		Available: int(available),
+		SwapTotal: int(memInfo["SwapTotal"]),
+		SwapUsed:  int(memInfo["SwapTotal"] - memInfo["SwapFree"]),
+	}, nil
 }
 
 func (e *EnvironmentGatherer) getFileSystems() ([]types.Storage, error) {
-	storage, err := e.commandRepository.GetStorage()
+	mounts, err := e.systemRepository.GetMounts()
 	if err != nil {
 		return nil, fmt.Errorf("Error getting storage info: %v", err)
 	}
 
 	var fileSystems []types.Storage
-	lines := strings.Split(strings.TrimSpace(*storage), "\n")
-	if len(lines) < 2 {
-		return nil, errors.New("Insufficient data in storage info")
-	}
+	mountPoints := map[string]int{}
 
-	// Parse header to get the index of each column
-	header := strings.Fields(lines[0])
-	columnIndices := map[string]int{}
-	for i, columnName := range header {
-		lowerColumnName := strings.ToLower(columnName)
-		switch lowerColumnName {
-		case "filesystem":
-			columnIndices["name"] = i
-		case "size":
-			columnIndices["size"] = i
-		case "used":
-			columnIndices["used"] = i
-		case "avail":
-			columnIndices["available"] = i
-		case "use%", "capacity":
-			columnIndices["usepercentage"] = i
-		case "mounted":
-			columnIndices["mountedon"] = i
+	for _, mount := range mounts {
+		stat, err := e.systemRepository.Statfs(mount.MountPoint)
+		if err != nil {
+			e.Logger.Debugf("Failed to stat filesystem %s: %v", mount.MountPoint, err)
+			continue
+		}
+
+		// Pseudo filesystems such as proc or sysfs have no size.
+		if stat.Size == 0 {
+			continue
+		}
+
+		used := stat.Size - stat.Free
+		usePercentage := 0.0
+		if used+stat.Available > 0 {
+			usePercentage = math.Ceil(float64(used) * 100 / float64(used+stat.Available))
 		}
-	}
 
-	for i := 1; i < len(lines); i++ {
-		fields := strings.Fields(lines[i])
 		fileSystem := types.Storage{
-			Name:          fields[columnIndices["name"]],
-			Size:          fields[columnIndices["size"]],
-			Used:          fields[columnIndices["used"]],
-			Available:     fields[columnIndices["available"]],
-			UsePercentage: fields[columnIndices["usepercentage"]],
-			MountedOn:     fields[columnIndices["mountedon"]],
+			Name:           mount.Device,
+			Size:           humanSize(stat.Size),
+			Used:           humanSize(used),
+			Available:      humanSize(stat.Available),
+			UsePercentage:  fmt.Sprintf("%.0f%%", usePercentage),
+			MountedOn:      mount.MountPoint,
+			FSType:         mount.FSType,
+			SizeBytes:      stat.Size,
+			UsedBytes:      used,
+			AvailableBytes: stat.Available,
+			Inodes:         stat.Files,
+			InodesUsed:     stat.Files - stat.FilesFree,
 		}
 
+		// A later mount over the same mount point hides the earlier one.
+		if index, found := mountPoints[mount.MountPoint]; found {
+			fileSystems[index] = fileSystem
+			continue
+		}
+
+		mountPoints[mount.MountPoint] = len(fileSystems)
 		fileSystems = append(fileSystems, fileSystem)
 	}
 
+	// Filter once duplicate mount points are resolved, statfs reports the filesystem mounted last.
+	var included []types.Storage
+	for _, fileSystem := range fileSystems {
+		if e.storageFilter.Includes(fileSystem.FSType, fileSystem.MountedOn) {
+			included = append(included, fileSystem)
+		}
+	}
+	fileSystems = included
+
+	if len(fileSystems) == 0 {
+		return nil, errors.New("Insufficient data in storage info")
+	}
+
 	return fileSystems, nil
 }
 
-func (e *EnvironmentGatherer) getCPUDetails() (*types.CPU, error) {
-	load := e.cpuLoadManager.GetLastCPULoad()
+func (e *EnvironmentGatherer) getDisks() ([]types.Disk, error) {
+	devices, err := e.systemRepository.GetBlockDevices()
+	if err != nil {
+		return nil, fmt.Errorf("Error getting block devices: %v", err)
+	}
 
-	cpuInfo, err := e.commandRepository.GetCPUInfo()
+	measurements, err := e.diskIOManager.Measure()
+	if err != nil {
+		return nil, fmt.Errorf("Error getting disk stats: %v", err)
+	}
+
+	disks := []types.Disk{}
+	for _, device := range devices {
+		disk := types.Disk{
+			Name:       device.Name,
+			Model:      device.Model,
+			Size:       device.Size,
+			Rotational: device.Rotational,
+			Removable:  device.Removable,
+		}
+
+		if measurement, found := measurements[device.Name]; found {
+			disk.Reads = measurement.Stat.ReadsCompleted
+			disk.Writes = measurement.Stat.WritesCompleted
+			disk.BytesRead = measurement.Stat.BytesRead()
+			disk.BytesWritten = measurement.Stat.BytesWritten()
+			disk.ReadTime = measurement.Stat.ReadTime
+			disk.WriteTime = measurement.Stat.WriteTime
+			disk.IOTime = measurement.Stat.IOTime
+			disk.InFlight = measurement.Stat.InFlight
+			disk.Rates = measurement.Rates
+		}
+
+		disks = append(disks, disk)
+	}
+
+	return disks, nil
+}
+
+// humanSize formats bytes the way df -h does, e.g. 1.9G or 29G.
+func humanSize(bytes uint64) string {
+	const units = "KMGTPE"
+
+	if bytes < 1024 {
+		return fmt.Sprintf("%d", bytes)
+	}
+
+	value := float64(bytes)
+	unit := -1
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+
+	// Sizes are rounded up, with one decimal below 10.
+	if value < 10 {
+		if rounded := math.Ceil(value*10) / 10; rounded < 10 {
+			return fmt.Sprintf("%.1f%c", rounded, units[unit])
+		}
+	}
+
+	return fmt.Sprintf("%.0f%c", math.Ceil(value), units[unit])
+}
+
+func (e *EnvironmentGatherer) getCPUDetails() (*types.CPU, error) {
+	cpuInfo, err := e.systemRepository.GetCPUInfo()
 	if err != nil {
 		return nil, fmt.Errorf("Error getting CPU info: %v", err)
 	}
 
-	cpuDetails := &types.CPU{ModelName: cpuInfo.Model, Architecture: cpuInfo.Architecture, Load: load, CPUMHz: cpuInfo.MHz}
+	cpuDetails := &types.CPU{ModelName: cpuInfo.Model, Architecture: cpuInfo.Architecture, CPUMHz: cpuInfo.MHz, CoreCount: cpuInfo.CPUCount}
+
+	measurement, err := e.cpuLoadManager.Measure()
+	if err != nil {
+		e.Logger.Errorf("Error measuring CPU load: %v", err)
+	} else {
+		cpuDetails.Load = measurement.Load
+		cpuDetails.Usage = &measurement.Usage
+		cpuDetails.CoreLoads = measurement.CoreLoads
+
+		if cpuDetails.CoreCount == 0 {
+			cpuDetails.CoreCount = len(measurement.CoreLoads)
+		}
+	}
+
+	loadAverage, err := e.systemRepository.GetLoadAverage()
+	if err != nil {
+		e.Logger.Errorf("Error getting load average: %v", err)
+	} else {
+		cpuDetails.LoadAverage = &types.LoadAverage{One: loadAverage.One, Five: loadAverage.Five, Fifteen: loadAverage.Fifteen}
+	}
 
 	return cpuDetails, nil
 }
 
-func (e *EnvironmentGatherer) getCPUTemperature() (float64, error) {
-	tempStr, err := e.commandRepository.GetCPUTemperature()
+// Sensor types measuring the CPU, in order of preference.
+var cpuSensorTypes = []string{"coretemp", "k10temp", "zenpower", "cpu_thermal", "cpu-thermal", "x86_pkg_temp", "soc_thermal", "cpu"}
+
+func (e *EnvironmentGatherer) getTemperatures() ([]types.TemperatureSensor, error) {
+	sensors, err := e.systemRepository.GetTemperatureSensors()
 	if err != nil {
-		return 0, fmt.Errorf("Error getting CPU temperature: %v", err)
+		return nil, fmt.Errorf("Error getting temperature sensors: %v", err)
 	}
 
-	temp, err := strconv.ParseFloat(strings.TrimSpace(*tempStr), 64)
-	if err != nil {
-		return 0, fmt.Errorf("Error parsing CPU temperature: %v", err)
+	temperatures := []types.TemperatureSensor{}
+	for _, sensor := range sensors {
+		temperature := types.TemperatureSensor{
+			Source:      sensor.Source,
+			Name:        sensor.Name,
+			Type:        sensor.Type,
+			Label:       sensor.Label,
+			Temperature: sensor.Temperature,
+		}
+		for _, tripPoint := range sensor.TripPoints {
+			temperature.TripPoints = append(temperature.TripPoints, types.TripPoint{Type: tripPoint.Type, Temperature: tripPoint.Temperature})
+		}
+
+		temperatures = append(temperatures, temperature)
 	}
 
-	return temp, nil
+	return temperatures, nil
+}
+
+// cpuTemperature picks the sensor measuring the CPU. thermal_zone0 is only the fallback,
+// as on many x86 machines it is the ACPI zone rather than the CPU.
+func cpuTemperature(temperatures []types.TemperatureSensor) (float64, bool) {
+	for _, sensorType := range cpuSensorTypes {
+		for _, temperature := range temperatures {
+			if temperature.Type == sensorType {
+				return math.Round(temperature.Temperature*10) / 10, true
+			}
+		}
+	}
+
+	for _, temperature := range temperatures {
+		if temperature.Name == "thermal_zone0" {
+			return math.Round(temperature.Temperature*10) / 10, true
+		}
+	}
+
+	return 0, false
 }
 
 func (e *EnvironmentGatherer) getLastBootTime() (string, error) {
-	bootTime, err := e.commandRepository.GetLastBootTime()
+	uptime, err := e.systemRepository.GetUptime()
 	if err != nil {
 		return "", fmt.Errorf("Error getting last boot time: %v", err)
 	}
 
-	return strings.TrimSpace(*bootTime), nil
+	return time.Now().Add(-uptime).Format("2006-01-02 15:04:05"), nil
 }
 
 func (e *EnvironmentGatherer) CalculateEnvironment() types.Environment {
@@ -171,6 +353,20 @@ func (e *EnvironmentGatherer) CalculateEnvironment() types.Environment {
 		environment.Storage = fileSystems
 	}
 
+	disks, err := e.getDisks()
+	if err != nil {
+		e.Logger.Error(err)
+	} else {
+		environment.Disks = disks
+	}
+
+	storageHealth, err := e.getStorageHealth()
+	if err != nil {
+		e.Logger.Error(err)
+	} else {
+		environment.StorageHealth = storageHealth
+	}
+
 	bootTime, err := e.getLastBootTime()
 	if err != nil {
 		e.Logger.Error(err)
@@ -178,20 +374,51 @@ func (e *EnvironmentGatherer) CalculateEnvironment() types.Environment {
 		environment.BootTime = bootTime
 	}
 
+	temperatures, err := e.getTemperatures()
+	if err != nil {
+		e.Logger.Error(err)
+	} else {
+		environment.Temperatures = temperatures
+	}
+
 	cpuDetails, err := e.getCPUDetails()
 	if err != nil {
 		e.Logger.Error(err)
 	} else {
 		environment.CPU = cpuDetails
 
-		cpuTemp, err := e.getCPUTemperature()
-		if err != nil {
-			e.Logger.Error(err)
-		} else {
+		if cpuTemp, found := cpuTemperature(temperatures); found {
 			environment.CPU.Temperature = cpuTemp
+		} else {
+			e.Logger.Error("Error getting CPU temperature: no CPU temperature sensor found")
 		}
 	}
 
+	pressure, err := e.getPressure()
+	if err != nil {
+		e.Logger.Debug(err)
+	} else {
+		environment.Pressure = pressure
+	}
+
+	virtualMemory, err := e.getVirtualMemory()
+	if err != nil {
+		e.Logger.Error(err)
+	} else {
+		environment.VirtualMemory = virtualMemory
+	}
+
+	if e.sampler != nil {
+		environment.Sampling = e.sampler.Summarize()
+	}
+
+	processes, err := e.processManager.Measure()
+	if err != nil {
+		e.Logger.Error(err)
+	} else {
+		environment.Processes = processes
+	}
+
 	network, err := e.getNetworkDetails()
 	if err != nil {
 		e.Logger.Error(err)
@@ -202,60 +429,93 @@ func (e *EnvironmentGatherer) CalculateEnvironment() types.Environment {
 	return environment
 }
 
-func (e *EnvironmentGatherer) PausePeriodicTasks() {
-	e.cpuLoadManager.Stop()
-}
-
-func (e *EnvironmentGatherer) ResumePeriodicTasks() {
-	e.cpuLoadManager.Start()
-}
-
 func (e *EnvironmentGatherer) getNetworkDetails() (*types.Network, error) {
-	networkInterfaces, err := e.commandRepository.GetNetworkInterfaces()
+	interfaces, err := e.systemRepository.GetNetworkInterfaces()
 	if err != nil {
 		return nil, fmt.Errorf("Error getting network interfaces: %v", err)
 	}
 
 	var networks *types.Network = &types.Network{Interfaces: []types.NetworkInterface{}}
 
-	interfaces := strings.Split(strings.TrimSpace(*networkInterfaces), "\n")
+	if defaultInterface, err := e.systemRepository.GetDefaultRouteInterface(); err == nil {
+		networks.DefaultInterface = defaultInterface
+	} else {
+		e.Logger.Debugf("Failed to find the default route: %v", err)
+	}
 
+	statistics := map[string]map[string]uint64{}
 	for _, iface := range interfaces {
-		rxBytes, err := e.commandRepository.GetRXTXBytes(iface, "rx")
+		counters, err := e.systemRepository.GetInterfaceStatistics(iface)
 		if err != nil {
-			e.Logger.Errorf("Failed to fetch RX bytes for interface %s: %v", iface, err)
+			e.Logger.Errorf("Failed to fetch statistics for interface %s: %v", iface, err)
 			continue
 		}
+		statistics[iface] = counters
+	}
 
-		txBytes, err := e.commandRepository.GetRXTXBytes(iface, "tx")
-		if err != nil {
-			e.Logger.Errorf("Failed to fetch TX bytes for interface %s: %v", iface, err)
+	rates := e.networkRateManager.Measure(statistics)
+
+	for _, iface := range interfaces {
+		counters, found := statistics[iface]
+		if !found {
 			continue
 		}
-		rxPackets, err := e.commandRepository.GetRXTXPackets(iface, "rx")
+
+		info, err := e.systemRepository.GetInterfaceInfo(iface)
 		if err != nil {
-			e.Logger.Errorf("Failed to fetch RX packets for interface %s: %v", iface, err)
+			e.Logger.Errorf("Failed to fetch link of interface %s: %v", iface, err)
 			continue
 		}
 
-		txPackets, err := e.commandRepository.GetRXTXPackets(iface, "tx")
+		networkInterface := types.NetworkInterface{
+			Name:      iface,
+			Rx:        interfaceData(counters, rates[iface], "rx"),
+			Tx:        interfaceData(counters, rates[iface], "tx"),
+			OperState: info.OperState,
+			MTU:       info.MTU,
+			Duplex:    info.Duplex,
+			MAC:       info.MAC,
+			IPv4:      []string{},
+			IPv6:      []string{},
+			Virtual:   info.Virtual,
+		}
+		if info.Speed > 0 {
+			networkInterface.Speed = &info.Speed
+		}
+
+		addresses, err := e.systemRepository.GetInterfaceAddresses(iface)
 		if err != nil {
-			e.Logger.Errorf("Failed to fetch TX packets for interface %s: %v", iface, err)
-			continue
+			e.Logger.Debugf("Failed to fetch addresses of interface %s: %v", iface, err)
+		}
+		for _, address := range addresses {
+			if strings.Contains(address, ":") {
+				networkInterface.IPv6 = append(networkInterface.IPv6, address)
+			} else {
+				networkInterface.IPv4 = append(networkInterface.IPv4, address)
+			}
 		}
 
-		networks.Interfaces = append(networks.Interfaces, types.NetworkInterface{
-			Name: iface,
-			Rx: &types.NetworkInterfaceData{
-				Bytes:   *rxBytes,
-				Packets: *rxPackets,
-			},
-			Tx: &types.NetworkInterfaceData{
-				Bytes:   *txBytes,
-				Packets: *txPackets,
-			},
-		})
+		networks.Interfaces = append(networks.Interfaces, networkInterface)
 	}
 
 	return networks, nil
 }
+
+// interfaceData picks the counters and rates of one direction, rx or tx.
+func interfaceData(counters map[string]uint64, rates map[string]float64, direction string) *types.NetworkInterfaceData {
+	data := &types.NetworkInterfaceData{
+		Bytes:   int(counters[direction+"_bytes"]),
+		Packets: int(counters[direction+"_packets"]),
+		Errors:  int(counters[direction+"_errors"]),
+		Dropped: int(counters[direction+"_dropped"]),
+	}
+
+	if bytesPerSecond, found := rates[direction+"_bytes"]; found {
+		data.BytesPerSecond = &bytesPerSecond
+	}
+	if packetsPerSecond, found := rates[direction+"_packets"]; found {
+		data.PacketsPerSecond = &packetsPerSecond
+	}
+
+	return data
+}
@@ -0,0 +1,317 @@
+package environmentgatherer
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/evilmint/haargos-agent-golang/repositories/procfsrepository"
+	"github.com/evilmint/haargos-agent-golang/types"
+	"github.com/sirupsen/logrus"
+)
+
+func TestEnvironmentGatherer_getMemoryInfo(t *testing.T) {
+	gatherer := NewEnvironmentGatherer(logrus.New(), procfsrepository.NewProcfsRepository(logrus.New(), "../../repositories/procfsrepository/testdata/rpi4"))
+
+	memory, err := gatherer.getMemoryInfo()
+	if err != nil {
+		t.Fatalf("getMemoryInfo() error = %v", err)
+	}
+
+	want := types.Memory{
+		Total:     3884328,
+		Used:      1156772,
+		Free:      990420,
+		Shared:    35404,
+		BuffCache: 1810464,
+		Available: 2727556,
+		SwapTotal: 102396,
+		SwapUsed:  10240,
+	}
+	if *memory != want {
+		t.Errorf("getMemoryInfo() = %+v, want %+v", *memory, want)
+	}
+}
+
+func TestHumanSize(t *testing.T) {
+	tests := []struct {
+		bytes uint64
+		want  string
+	}{
+		{0, "0"},
+		{1000, "1000"},
+		{1536, "1.5K"},
+		{2040109465, "1.9G"},
+		{31138512896, "29G"},
+		{10 * 1024 * 1024 * 1024 * 1024, "10T"},
+	}
+
+	for _, tt := range tests {
+		if got := humanSize(tt.bytes); got != tt.want {
+			t.Errorf("humanSize(%d) = %s, want %s", tt.bytes, got, tt.want)
+		}
+	}
+}
+
+func TestCPUUsage(t *testing.T) {
+	previous := procfsrepository.CPUTimes{User: 100, System: 50, Idle: 800, IOWait: 50}
+	current := procfsrepository.CPUTimes{User: 150, Nice: 10, System: 70, Idle: 900, IOWait: 70}
+
+	usage, ok := cpuUsage(previous, current)
+	want := types.CPUUsage{User: 30, System: 10, IOWait: 10, Idle: 50}
+	if !ok || usage != want {
+		t.Errorf("cpuUsage() = %+v, %v, want %+v", usage, ok, want)
+	}
+
+	if _, ok := cpuUsage(current, current); ok {
+		t.Errorf("cpuUsage() of identical samples should not be ok")
+	}
+}
+
+func TestCPUTemperature(t *testing.T) {
+	for _, fixture := range []struct {
+		name string
+		want float64
+	}{
+		{"rpi4", 48.7},
+		{"nuc", 53},
+	} {
+		gatherer := NewEnvironmentGatherer(logrus.New(), procfsrepository.NewProcfsRepository(logrus.New(), "../../repositories/procfsrepository/testdata/"+fixture.name))
+
+		temperatures, err := gatherer.getTemperatures()
+		if err != nil {
+			t.Fatalf("getTemperatures() error = %v", err)
+		}
+
+		if got, found := cpuTemperature(temperatures); !found || got != fixture.want {
+			t.Errorf("cpuTemperature() on %s = %v, %v, want %v", fixture.name, got, found, fixture.want)
+		}
+	}
+
+	acpiOnly := []types.TemperatureSensor{{Source: "thermal", Name: "thermal_zone0", Type: "acpitz", Temperature: 27.8}}
+	if got, found := cpuTemperature(acpiOnly); !found || got != 27.8 {
+		t.Errorf("cpuTemperature() = %v, %v, want thermal_zone0 as the fallback", got, found)
+	}
+}
+
+func TestDiskRates(t *testing.T) {
+	previous := procfsrepository.DiskStat{ReadsCompleted: 100, SectorsRead: 2000, WritesCompleted: 500, SectorsWritten: 8000, IOTime: 1000, WeightedIOTime: 3000}
+	current := procfsrepository.DiskStat{ReadsCompleted: 120, SectorsRead: 4000, WritesCompleted: 1100, SectorsWritten: 48000, IOTime: 6000, WeightedIOTime: 23000}
+
+	rates, ok := diskRates(previous, current, 10*time.Second)
+	want := types.DiskRates{
+		ReadsPerSecond:        2,
+		WritesPerSecond:       60,
+		BytesReadPerSecond:    102400,
+		BytesWrittenPerSecond: 2048000,
+		Utilization:           50,
+		QueueDepth:            2,
+	}
+	if !ok || rates != want {
+		t.Errorf("diskRates() = %+v, %v, want %+v", rates, ok, want)
+	}
+
+	if _, ok := diskRates(current, previous, 10*time.Second); ok {
+		t.Errorf("diskRates() of counters going backwards should not be ok")
+	}
+}
+
+func TestEnvironmentGatherer_getStorageHealth(t *testing.T) {
+	gatherer := NewEnvironmentGatherer(logrus.New(), procfsrepository.NewProcfsRepository(logrus.New(), "../../repositories/procfsrepository/testdata/green"))
+
+	health, err := gatherer.getStorageHealth()
+	if err != nil {
+		t.Fatalf("getStorageHealth() error = %v", err)
+	}
+
+	if len(health.Disks) != 1 || *health.Disks[0].LifeTimeUsedA != 20 || *health.Disks[0].LifeTimeUsedB != 10 || health.Disks[0].PreEOL != "normal" {
+		t.Errorf("getStorageHealth() disks = %+v, want the eMMC 20%%/10%% worn", health.Disks)
+	}
+
+	// The read-only root filesystem is left out and the bind mounted data partition is reported once.
+	var mountedOn []string
+	for _, fileSystem := range health.FileSystems {
+		mountedOn = append(mountedOn, fileSystem.MountedOn)
+	}
+	if !reflect.DeepEqual(mountedOn, []string{"/mnt/boot", "/mnt/data", "/mnt/overlay"}) {
+		t.Fatalf("getStorageHealth() filesystems = %v", mountedOn)
+	}
+
+	data := health.FileSystems[1]
+	if !data.ReadOnly || data.Errors == nil || *data.Errors != 2 {
+		t.Errorf("getStorageHealth() data partition = %+v, want read-only with 2 errors", data)
+	}
+	if health.FileSystems[2].ReadOnly || health.FileSystems[2].Errors != nil {
+		t.Errorf("getStorageHealth() overlay partition = %+v, want writable without error count", health.FileSystems[2])
+	}
+}
+
+func TestCounterRateManager(t *testing.T) {
+	manager := NewCounterRateManager(logrus.New())
+
+	if rates := manager.Measure(map[string]map[string]uint64{"eth0": {"rx_bytes": 1000}}); len(rates) != 0 {
+		t.Errorf("Measure() of the first sample = %v, want no rates", rates)
+	}
+
+	manager.lastSampleTime = manager.lastSampleTime.Add(-2 * time.Second)
+	rates := manager.Measure(map[string]map[string]uint64{"eth0": {"rx_bytes": 5000}, "veth1": {"rx_bytes": 10}})
+
+	if _, found := rates["veth1"]; found {
+		t.Errorf("Measure() = %v, want no rates for an interface seen for the first time", rates)
+	}
+	if rate := rates["eth0"]["rx_bytes"]; rate < 1990 || rate > 2000 {
+		t.Errorf("Measure() rx_bytes rate = %v, want about 2000", rate)
+	}
+}
+
+func TestProcessManager_Measure(t *testing.T) {
+	manager := NewProcessManager(logrus.New(), procfsrepository.NewProcfsRepository(logrus.New(), "../../repositories/procfsrepository/testdata/rpi4"))
+
+	// Pretend Home Assistant used 50 ticks of the last 10 seconds.
+	manager.lastSample[processKey{612, 2410}] -= 50
+	manager.lastSampleTime = time.Now().Add(-10 * time.Second)
+
+	processes, err := manager.Measure()
+	if err != nil {
+		t.Fatalf("Measure() error = %v", err)
+	}
+
+	if len(processes.TopMemory) != 4 || processes.TopMemory[0].Name != "python3" || processes.TopMemory[1].Name != "haargos" {
+		t.Errorf("Measure() top memory = %+v, want python3 and haargos first", processes.TopMemory)
+	}
+	if cpu := processes.TopCPU[0].CPU; processes.TopCPU[0].PID != 612 || cpu < 4.9 || cpu > 5 {
+		t.Errorf("Measure() top CPU = %+v, want python3 at 5%%", processes.TopCPU[0])
+	}
+
+	want := "/usr/bin/haargos run --ha-config /config --token **REDACTED** --api-key=**REDACTED** **REDACTED**"
+	if haargos := processes.TopMemory[1]; haargos.Command != want || haargos.User != "pi" {
+		t.Errorf("Measure() haargos = %+v, want command %q by pi", haargos, want)
+	}
+	if kworker := processes.TopMemory[3]; kworker.Command != "[kworker/1:0H-mmc_complete]" {
+		t.Errorf("Measure() kernel thread command = %q", kworker.Command)
+	}
+}
+
+func TestProcessCommand_truncatesAtCharacterBoundary(t *testing.T) {
+	// Cutting after 512 bytes would split "é".
+	command := processCommand(procfsrepository.Process{Command: []string{strings.Repeat("a", 511) + "éa"}})
+
+	if !utf8.ValidString(command) || command != strings.Repeat("a", 511)+"..." {
+		t.Errorf("processCommand() = %q, want the command cut before the split character", command)
+	}
+}
+
+func TestEnvironmentGatherer_getVirtualMemory(t *testing.T) {
+	gatherer := NewEnvironmentGatherer(logrus.New(), procfsrepository.NewProcfsRepository(logrus.New(), "../../repositories/procfsrepository/testdata/rpi4"))
+
+	virtualMemory, err := gatherer.getVirtualMemory()
+	if err != nil {
+		t.Fatalf("getVirtualMemory() error = %v", err)
+	}
+
+	if virtualMemory.OOMKills != 3 || virtualMemory.SwapInPagesPerSecond != nil {
+		t.Errorf("getVirtualMemory() = %+v, want 3 OOM kills and no swap rates on the first observation", virtualMemory)
+	}
+	if len(virtualMemory.Zram) != 1 || virtualMemory.Zram[0].Algorithm != "zstd" {
+		t.Errorf("getVirtualMemory() zram = %+v", virtualMemory.Zram)
+	}
+	if zswap := virtualMemory.Zswap; zswap == nil || zswap.Enabled || zswap.MaxPoolPercent != 20 || zswap.PoolSize != nil {
+		t.Errorf("getVirtualMemory() zswap = %+v, want disabled without pool sizes", zswap)
+	}
+
+	virtualMemory, _ = gatherer.getVirtualMemory()
+	if virtualMemory.SwapInPagesPerSecond == nil || *virtualMemory.SwapInPagesPerSecond != 0 {
+		t.Errorf("getVirtualMemory() swap in rate = %v, want 0", virtualMemory.SwapInPagesPerSecond)
+	}
+}
+
+func TestEnvironmentGatherer_getFileSystems(t *testing.T) {
+	gatherer := NewEnvironmentGatherer(logrus.New(), procfsrepository.NewProcfsRepository(logrus.New(), "../../repositories/procfsrepository/testdata/rpi4"))
+
+	fileSystems, err := gatherer.getFileSystems()
+	if err != nil {
+		t.Fatalf("getFileSystems() error = %v", err)
+	}
+
+	root := fileSystems[0]
+	if root.MountedOn != "/" || root.FSType != "ext4" || root.SizeBytes == 0 || humanSize(root.SizeBytes) != root.Size {
+		t.Errorf("getFileSystems()[0] = %+v, want / with the size in bytes matching %s", root, root.Size)
+	}
+	if root.UsedBytes+root.AvailableBytes > root.SizeBytes || root.InodesUsed > root.Inodes {
+		t.Errorf("getFileSystems()[0] = %+v, want used and available within the size", root)
+	}
+
+	gatherer.SetStorageFilter(StorageFilter{ExcludeFSTypes: []string{"ext4"}})
+	fileSystems, _ = gatherer.getFileSystems()
+	for _, fileSystem := range fileSystems {
+		if fileSystem.FSType == "ext4" {
+			t.Errorf("getFileSystems() = %+v, want the ext4 root filtered out", fileSystems)
+		}
+	}
+}
+
+func TestStorageFilter_Includes(t *testing.T) {
+	tests := []struct {
+		filter     StorageFilter
+		fsType     string
+		mountPoint string
+		want       bool
+	}{
+		{StorageFilter{}, "tmpfs", "/run", true},
+		{StorageFilter{ExcludeFSTypes: []string{"tmpfs"}}, "tmpfs", "/run", false},
+		{StorageFilter{IncludeFSTypes: []string{"ext4"}}, "vfat", "/boot", false},
+		{StorageFilter{ExcludeMountPoints: []string{"/var/lib/docker"}}, "overlay", "/var/lib/docker/overlay2/3f1a/merged", false},
+		{StorageFilter{ExcludeMountPoints: []string{"/var/lib/docker"}}, "ext4", "/var/lib/dockerd", true},
+		{StorageFilter{IncludeMountPoints: []string{"/mnt/data/"}}, "ext4", "/mnt/data", true},
+		{StorageFilter{IncludeMountPoints: []string{"/mnt/data"}, ExcludeMountPoints: []string{"/mnt/data/docker"}}, "ext4", "/mnt/data/docker", false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.filter.Includes(tt.fsType, tt.mountPoint); got != tt.want {
+			t.Errorf("%+v.Includes(%s, %s) = %v, want %v", tt.filter, tt.fsType, tt.mountPoint, got, tt.want)
+		}
+	}
+}
+
+func TestSampleStatistics(t *testing.T) {
+	values := []float64{math.NaN()}
+	for i := 20; i >= 1; i-- {
+		values = append(values, float64(i))
+	}
+
+	want := types.SampleStatistics{Min: 1, Max: 20, Avg: 10.5, P95: 19}
+	if got := sampleStatistics(values); got == nil || *got != want {
+		t.Errorf("sampleStatistics() = %+v, want %+v", got, want)
+	}
+
+	if got := sampleStatistics([]float64{math.NaN()}); got != nil {
+		t.Errorf("sampleStatistics() without values = %+v, want nil", got)
+	}
+}
+
+func TestEnvironmentSampler_Summarize(t *testing.T) {
+	sampler := NewEnvironmentSampler(logrus.New(), procfsrepository.NewProcfsRepository(logrus.New(), "../../repositories/procfsrepository/testdata/rpi4"), time.Second, 3)
+
+	start := time.Now()
+	for i := 1; i <= 4; i++ {
+		sampler.sample(start.Add(time.Duration(i) * time.Second))
+	}
+
+	// The ring buffer keeps the last 3 samples. The fixture's CPU counters do not advance,
+	// so no CPU load can be computed, while its disks are idle.
+	sampling := sampler.Summarize()
+	if sampling.Count != 3 || sampling.CPULoad != nil || sampling.DiskWriteBytesPerSecond == nil || sampling.DiskWriteBytesPerSecond.Max != 0 {
+		t.Fatalf("Summarize() = %+v, want 3 samples with idle disks and no CPU load", sampling)
+	}
+	if sampling.CPUTemperature.Max != 48.7 || sampling.MemoryUsed.Min < 29 || sampling.MemoryUsed.Max > 30 {
+		t.Errorf("Summarize() = %+v, %+v, want the fixture's temperature and memory", sampling.CPUTemperature, sampling.MemoryUsed)
+	}
+
+	sampler.sample(start.Add(5 * time.Second))
+	if sampling := sampler.Summarize(); sampling.Count != 1 {
+		t.Errorf("Summarize() count = %d, want only the sample taken since the previous summary", sampling.Count)
+	}
+}
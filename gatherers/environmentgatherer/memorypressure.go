@@ -0,0 +1,92 @@
+package environmentgatherer
+
+import (
+	"fmt"
+
+	"github.com/evilmint/haargos-agent-golang/repositories/procfsrepository"
+	"github.com/evilmint/haargos-agent-golang/types"
+)
+
+func (e *EnvironmentGatherer) getPressure() (*types.Pressure, error) {
+	pressure := &types.Pressure{}
+
+	for resource, target := range map[string]**types.ResourcePressure{
+		"cpu":    &pressure.CPU,
+		"memory": &pressure.Memory,
+		"io":     &pressure.IO,
+	} {
+		resourcePressure, err := e.systemRepository.GetPressure(resource)
+		if err != nil {
+			return nil, fmt.Errorf("Error getting %s pressure: %v", resource, err)
+		}
+
+		*target = &types.ResourcePressure{
+			Some: pressureStall(resourcePressure.Some),
+			Full: pressureStall(resourcePressure.Full),
+		}
+	}
+
+	return pressure, nil
+}
+
+func pressureStall(stall *procfsrepository.PressureStall) *types.PressureStall {
+	if stall == nil {
+		return nil
+	}
+
+	return &types.PressureStall{Avg10: stall.Avg10, Avg60: stall.Avg60, Avg300: stall.Avg300, Total: stall.Total}
+}
+
+func (e *EnvironmentGatherer) getVirtualMemory() (*types.VirtualMemory, error) {
+	vmStat, err := e.systemRepository.GetVMStat()
+	if err != nil {
+		return nil, fmt.Errorf("Error getting vmstat: %v", err)
+	}
+
+	virtualMemory := &types.VirtualMemory{OOMKills: vmStat["oom_kill"], Zram: []types.Zram{}}
+
+	rates := e.vmStatRateManager.Measure(map[string]map[string]uint64{
+		"swap": {"pswpin": vmStat["pswpin"], "pswpout": vmStat["pswpout"]},
+	})
+	if swapIn, found := rates["swap"]["pswpin"]; found {
+		virtualMemory.SwapInPagesPerSecond = &swapIn
+	}
+	if swapOut, found := rates["swap"]["pswpout"]; found {
+		virtualMemory.SwapOutPagesPerSecond = &swapOut
+	}
+
+	zramDevices, err := e.systemRepository.GetZramDevices()
+	if err != nil {
+		e.Logger.Debugf("Failed to read zram devices: %v", err)
+	}
+	for _, device := range zramDevices {
+		virtualMemory.Zram = append(virtualMemory.Zram, types.Zram{
+			Name:           device.Name,
+			Algorithm:      device.Algorithm,
+			DiskSize:       device.DiskSize,
+			OriginalSize:   device.OriginalSize,
+			CompressedSize: device.CompressedSize,
+			MemoryUsed:     device.MemoryUsed,
+			MemoryLimit:    device.MemoryLimit,
+		})
+	}
+
+	if parameters, err := e.systemRepository.GetZswapParameters(); err == nil {
+		zswap := &types.Zswap{Enabled: parameters.Enabled, Compressor: parameters.Compressor, MaxPoolPercent: parameters.MaxPoolPercent}
+
+		if memInfo, err := e.systemRepository.GetMemInfo(); err == nil {
+			if poolSize, found := memInfo["Zswap"]; found {
+				poolSize *= 1024
+				zswap.PoolSize = &poolSize
+			}
+			if storedSize, found := memInfo["Zswapped"]; found {
+				storedSize *= 1024
+				zswap.StoredSize = &storedSize
+			}
+		}
+
+		virtualMemory.Zswap = zswap
+	}
+
+	return virtualMemory, nil
+}
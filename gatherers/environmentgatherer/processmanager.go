@@ -0,0 +1,193 @@
+package environmentgatherer
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/evilmint/haargos-agent-golang/repositories/procfsrepository"
+	"github.com/evilmint/haargos-agent-golang/types"
+	"github.com/sirupsen/logrus"
+)
+
+// The number of processes reported by memory and by CPU usage.
+const topProcessCount = 10
+
+// USER_HZ, the unit of the CPU times in /proc, which is 100 on every architecture Linux supports.
+const clockTicksPerSecond = 100
+
+const maxCommandLength = 512
+
+const redactedArgument = "**REDACTED**"
+
+// Arguments named like this have their value redacted, e.g. --token=... or --password ....
+var sensitiveArgumentParts = []string{"password", "passwd", "token", "secret", "api_key", "apikey", "api-key", "credential", "auth"}
+
+// Long random looking arguments, e.g. access tokens, JWTs or hex keys.
+var tokenArgument = regexp.MustCompile(`^[A-Za-z0-9_\-.+=]{32,}$`)
+
+// ProcessManager finds the processes using the most memory and CPU since the previous measurement.
+type ProcessManager struct {
+	Logger           *logrus.Logger
+	systemRepository SystemRepository
+	lastSample       map[processKey]uint64
+	lastSampleTime   time.Time
+	mutex            sync.Mutex
+}
+
+type processKey struct {
+	PID       int
+	StartTime uint64
+}
+
+func NewProcessManager(logger *logrus.Logger, systemRepository SystemRepository) *ProcessManager {
+	manager := &ProcessManager{
+		Logger:           logger,
+		systemRepository: systemRepository,
+	}
+
+	if processes, err := systemRepository.GetProcesses(); err == nil {
+		manager.lastSample = cpuTimesByProcess(processes)
+		manager.lastSampleTime = time.Now()
+	}
+
+	return manager
+}
+
+func (p *ProcessManager) Measure() (*types.Processes, error) {
+	processes, err := p.systemRepository.GetProcesses()
+	if err != nil {
+		return nil, fmt.Errorf("Error getting processes: %v", err)
+	}
+	now := time.Now()
+
+	users, err := p.systemRepository.GetUserNames()
+	if err != nil {
+		p.Logger.Debugf("Failed to read user names: %v", err)
+	}
+
+	p.mutex.Lock()
+	previous, previousTime := p.lastSample, p.lastSampleTime
+	p.lastSample, p.lastSampleTime = cpuTimesByProcess(processes), now
+	p.mutex.Unlock()
+
+	elapsedTicks := now.Sub(previousTime).Seconds() * clockTicksPerSecond
+
+	var reported []types.Process
+	for _, process := range processes {
+		reportedProcess := types.Process{
+			PID:     process.PID,
+			Name:    process.Name,
+			Command: processCommand(process),
+			User:    userName(users, process.UID),
+			RSS:     process.RSS,
+		}
+
+		// Processes missing from the previous sample were started since.
+		if previous != nil && elapsedTicks > 0 {
+			previousCPUTime := previous[processKey{process.PID, process.StartTime}]
+			if process.CPUTime >= previousCPUTime {
+				reportedProcess.CPU = float64(process.CPUTime-previousCPUTime) * 100 / elapsedTicks
+			}
+		}
+
+		reported = append(reported, reportedProcess)
+	}
+
+	return &types.Processes{
+		TopMemory: topProcesses(reported, func(a, b types.Process) bool { return a.RSS > b.RSS }),
+		TopCPU:    topProcesses(reported, func(a, b types.Process) bool { return a.CPU > b.CPU }),
+	}, nil
+}
+
+func topProcesses(processes []types.Process, less func(a, b types.Process) bool) []types.Process {
+	sorted := make([]types.Process, len(processes))
+	copy(sorted, processes)
+
+	sort.SliceStable(sorted, func(a, b int) bool {
+		return less(sorted[a], sorted[b])
+	})
+
+	if len(sorted) > topProcessCount {
+		sorted = sorted[:topProcessCount]
+	}
+	return sorted
+}
+
+func cpuTimesByProcess(processes []procfsrepository.Process) map[processKey]uint64 {
+	cpuTimes := map[processKey]uint64{}
+	for _, process := range processes {
+		cpuTimes[processKey{process.PID, process.StartTime}] = process.CPUTime
+	}
+	return cpuTimes
+}
+
+func userName(users map[int]string, uid int) string {
+	if name, found := users[uid]; found {
+		return name
+	}
+	if uid < 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d", uid)
+}
+
+// processCommand joins the command line with secrets redacted. Kernel threads have none
+// and are shown by their name in brackets, like ps does.
+func processCommand(process procfsrepository.Process) string {
+	if len(process.Command) == 0 {
+		return fmt.Sprintf("[%s]", process.Name)
+	}
+
+	command := strings.Join(redactArguments(process.Command), " ")
+	if len(command) > maxCommandLength {
+		// Cut at the start of a character, so a multi-byte character is not split.
+		end := maxCommandLength
+		for end > 0 && !utf8.RuneStart(command[end]) {
+			end--
+		}
+		command = command[:end] + "..."
+	}
+	return command
+}
+
+func redactArguments(args []string) []string {
+	redacted := make([]string, len(args))
+
+	for i, arg := range args {
+		switch {
+		case i > 0 && isSensitiveArgument(args[i-1]) && strings.HasPrefix(args[i-1], "-") && !strings.Contains(args[i-1], "="):
+			redacted[i] = redactedArgument
+		case strings.Contains(arg, "=") && isSensitiveArgument(strings.SplitN(arg, "=", 2)[0]):
+			redacted[i] = strings.SplitN(arg, "=", 2)[0] + "=" + redactedArgument
+		case isTokenLike(arg):
+			redacted[i] = redactedArgument
+		default:
+			redacted[i] = arg
+		}
+	}
+
+	return redacted
+}
+
+func isSensitiveArgument(name string) bool {
+	name = strings.ToLower(name)
+	for _, part := range sensitiveArgumentParts {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTokenLike tells long arguments mixing letters and digits, unlike words, paths or numbers.
+func isTokenLike(arg string) bool {
+	if !tokenArgument.MatchString(arg) {
+		return false
+	}
+	return strings.ContainsAny(arg, "0123456789") && strings.ContainsAny(strings.ToLower(arg), "abcdefghijklmnopqrstuvwxyz")
+}
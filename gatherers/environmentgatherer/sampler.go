@@ -0,0 +1,210 @@
+package environmentgatherer
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/evilmint/haargos-agent-golang/repositories/procfsrepository"
+	"github.com/evilmint/haargos-agent-golang/types"
+	"github.com/sirupsen/logrus"
+)
+
+// EnvironmentSampler samples the CPU, memory, temperature and disk I/O at a short interval into
+// a ring buffer, so spikes between observations show up in their statistics.
+type EnvironmentSampler struct {
+	Logger           *logrus.Logger
+	systemRepository SystemRepository
+	interval         time.Duration
+	lastCPUStat      *procfsrepository.CPUStat
+	lastDiskStats    map[string]procfsrepository.DiskStat
+	lastDiskTime     time.Time
+	samples          []environmentSample
+	next             int
+	count            int
+	lastSummary      time.Time
+	mutex            sync.Mutex
+}
+
+// environmentSample holds one value per metric, NaN where it could not be read.
+type environmentSample struct {
+	time           time.Time
+	cpuLoad        float64
+	memoryUsed     float64
+	cpuTemperature float64
+	diskRead       float64
+	diskWrite      float64
+}
+
+func NewEnvironmentSampler(logger *logrus.Logger, systemRepository SystemRepository, interval time.Duration, capacity int) *EnvironmentSampler {
+	return &EnvironmentSampler{
+		Logger:           logger,
+		systemRepository: systemRepository,
+		interval:         interval,
+		samples:          make([]environmentSample, capacity),
+		lastSummary:      time.Now(),
+	}
+}
+
+// Start samples in the background until the agent exits.
+func (s *EnvironmentSampler) Start() {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		s.sample(time.Now())
+
+		for now := range ticker.C {
+			s.sample(now)
+		}
+	}()
+}
+
+func (s *EnvironmentSampler) sample(now time.Time) {
+	sample := environmentSample{
+		time:           now,
+		cpuLoad:        math.NaN(),
+		memoryUsed:     math.NaN(),
+		cpuTemperature: math.NaN(),
+		diskRead:       math.NaN(),
+		diskWrite:      math.NaN(),
+	}
+
+	if stat, err := s.systemRepository.GetCPUStat(); err == nil {
+		if s.lastCPUStat != nil {
+			if usage, ok := cpuUsage(s.lastCPUStat.Total, stat.Total); ok {
+				sample.cpuLoad = 100 - usage.Idle - usage.IOWait
+			}
+		}
+		s.lastCPUStat = stat
+	}
+
+	if memInfo, err := s.systemRepository.GetMemInfo(); err == nil {
+		if available, found := memInfo["MemAvailable"]; found && memInfo["MemTotal"] > 0 {
+			sample.memoryUsed = float64(int64(memInfo["MemTotal"])-int64(available)) * 100 / float64(memInfo["MemTotal"])
+		}
+	}
+
+	if sensors, err := s.systemRepository.GetTemperatureSensors(); err == nil {
+		var temperatures []types.TemperatureSensor
+		for _, sensor := range sensors {
+			temperatures = append(temperatures, types.TemperatureSensor{Name: sensor.Name, Type: sensor.Type, Temperature: sensor.Temperature})
+		}
+		if temperature, found := cpuTemperature(temperatures); found {
+			sample.cpuTemperature = temperature
+		}
+	}
+
+	s.sampleDisks(&sample)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.samples[s.next] = sample
+	s.next = (s.next + 1) % len(s.samples)
+	if s.count < len(s.samples) {
+		s.count++
+	}
+}
+
+// sampleDisks sums the throughput of the whole disks, leaving out partitions counted twice.
+func (s *EnvironmentSampler) sampleDisks(sample *environmentSample) {
+	devices, err := s.systemRepository.GetBlockDevices()
+	if err != nil {
+		return
+	}
+	stats, err := s.systemRepository.GetDiskStats()
+	if err != nil {
+		return
+	}
+
+	current := diskStatsByName(stats)
+	previous, previousTime := s.lastDiskStats, s.lastDiskTime
+	s.lastDiskStats, s.lastDiskTime = current, sample.time
+
+	if previous == nil {
+		return
+	}
+
+	var read, write float64
+	for _, device := range devices {
+		previousStat, found := previous[device.Name]
+		if !found {
+			continue
+		}
+		if rates, ok := diskRates(previousStat, current[device.Name], sample.time.Sub(previousTime)); ok {
+			read += rates.BytesReadPerSecond
+			write += rates.BytesWrittenPerSecond
+		}
+	}
+
+	sample.diskRead = read
+	sample.diskWrite = write
+}
+
+// Summarize returns the statistics of the samples taken since the previous summary.
+func (s *EnvironmentSampler) Summarize() *types.Sampling {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var samples []environmentSample
+	for i := 0; i < s.count; i++ {
+		sample := s.samples[(s.next-s.count+i+len(s.samples))%len(s.samples)]
+		if sample.time.After(s.lastSummary) {
+			samples = append(samples, sample)
+		}
+	}
+	if len(samples) > 0 {
+		s.lastSummary = samples[len(samples)-1].time
+	}
+
+	metric := func(value func(environmentSample) float64) *types.SampleStatistics {
+		var values []float64
+		for _, sample := range samples {
+			values = append(values, value(sample))
+		}
+		return sampleStatistics(values)
+	}
+
+	return &types.Sampling{
+		Interval:                s.interval.Seconds(),
+		Count:                   len(samples),
+		CPULoad:                 metric(func(sample environmentSample) float64 { return sample.cpuLoad }),
+		MemoryUsed:              metric(func(sample environmentSample) float64 { return sample.memoryUsed }),
+		CPUTemperature:          metric(func(sample environmentSample) float64 { return sample.cpuTemperature }),
+		DiskReadBytesPerSecond:  metric(func(sample environmentSample) float64 { return sample.diskRead }),
+		DiskWriteBytesPerSecond: metric(func(sample environmentSample) float64 { return sample.diskWrite }),
+	}
+}
+
+// sampleStatistics returns the minimum, maximum, average and 95th percentile of the values
+// which are not NaN, or nil without any.
+func sampleStatistics(values []float64) *types.SampleStatistics {
+	var valid []float64
+	for _, value := range values {
+		if !math.IsNaN(value) {
+			valid = append(valid, value)
+		}
+	}
+	if len(valid) == 0 {
+		return nil
+	}
+
+	sort.Float64s(valid)
+
+	sum := 0.0
+	for _, value := range valid {
+		sum += value
+	}
+
+	// The nearest-rank percentile, i.e. a value which was actually sampled.
+	p95 := valid[int(math.Ceil(0.95*float64(len(valid))))-1]
+
+	return &types.SampleStatistics{
+		Min: valid[0],
+		Max: valid[len(valid)-1],
+		Avg: sum / float64(len(valid)),
+		P95: p95,
+	}
+}
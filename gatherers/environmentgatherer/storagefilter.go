@@ -0,0 +1,39 @@
+package environmentgatherer
+
+import (
+	"path"
+	"strings"
+)
+
+// StorageFilter selects the filesystems reported as storage. Empty include lists include
+// everything, exclusions win over inclusions. Mount points also match the mounts beneath
+// them, e.g. /var/lib/docker matches /var/lib/docker/overlay2/.../merged.
+type StorageFilter struct {
+	IncludeFSTypes     []string
+	ExcludeFSTypes     []string
+	IncludeMountPoints []string
+	ExcludeMountPoints []string
+}
+
+func (f StorageFilter) Includes(fsType string, mountPoint string) bool {
+	if len(f.IncludeFSTypes) > 0 && !contains(f.IncludeFSTypes, fsType) {
+		return false
+	}
+	if contains(f.ExcludeFSTypes, fsType) {
+		return false
+	}
+	if len(f.IncludeMountPoints) > 0 && !matchesMountPoint(f.IncludeMountPoints, mountPoint) {
+		return false
+	}
+	return !matchesMountPoint(f.ExcludeMountPoints, mountPoint)
+}
+
+func matchesMountPoint(mountPoints []string, mountPoint string) bool {
+	for _, candidate := range mountPoints {
+		candidate = path.Clean(candidate)
+		if mountPoint == candidate || candidate == "/" || strings.HasPrefix(mountPoint, candidate+"/") {
+			return true
+		}
+	}
+	return false
+}
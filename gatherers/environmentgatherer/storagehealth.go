@@ -0,0 +1,95 @@
+package environmentgatherer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/evilmint/haargos-agent-golang/repositories/procfsrepository"
+	"github.com/evilmint/haargos-agent-golang/types"
+)
+
+// Filesystems which are read-only by design, e.g. the Home Assistant OS root filesystem.
+var readOnlyFSTypes = []string{"squashfs", "erofs", "iso9660", "udf"}
+
+var preEOLStates = map[uint64]string{1: "normal", 2: "warning", 3: "urgent"}
+
+func (e *EnvironmentGatherer) getStorageHealth() (*types.StorageHealth, error) {
+	devices, err := e.systemRepository.GetBlockDevices()
+	if err != nil {
+		return nil, fmt.Errorf("Error getting block devices: %v", err)
+	}
+
+	mounts, err := e.systemRepository.GetMountInfo()
+	if err != nil {
+		return nil, fmt.Errorf("Error getting mount info: %v", err)
+	}
+
+	health := &types.StorageHealth{Disks: []types.DiskHealth{}, FileSystems: []types.FileSystemHealth{}}
+
+	for _, device := range devices {
+		if diskHealth, found := e.systemRepository.GetDiskHealth(device.Name); found {
+			health.Disks = append(health.Disks, diskHealthFor(device.Name, diskHealth))
+		}
+	}
+
+	// Bind mounts list a filesystem several times, report it once.
+	seen := map[string]bool{}
+	for _, mount := range mounts {
+		if !strings.HasPrefix(mount.Device, "/dev/") || contains(readOnlyFSTypes, mount.FSType) || seen[mount.DeviceNumber] {
+			continue
+		}
+		seen[mount.DeviceNumber] = true
+
+		fileSystem := types.FileSystemHealth{
+			Device:    mount.Device,
+			MountedOn: mount.MountPoint,
+			FSType:    mount.FSType,
+			ReadOnly:  contains(mount.SuperOptions, "ro"),
+		}
+
+		if fileSystemErrors, err := e.systemRepository.GetFileSystemErrors(mount); err == nil {
+			fileSystem.Errors = &fileSystemErrors.Errors
+			if fileSystemErrors.LifetimeWrites > 0 {
+				fileSystem.BytesWritten = &fileSystemErrors.LifetimeWrites
+			}
+		}
+
+		if fileSystem.ReadOnly {
+			e.Logger.Warningf("Filesystem is mounted read-only [device=%s, mount=%s]", mount.Device, mount.MountPoint)
+		}
+
+		health.FileSystems = append(health.FileSystems, fileSystem)
+	}
+
+	return health, nil
+}
+
+func diskHealthFor(name string, health *procfsrepository.DiskHealth) types.DiskHealth {
+	diskHealth := types.DiskHealth{
+		Name:             name,
+		Type:             strings.ToLower(health.Type),
+		PreEOL:           preEOLStates[health.PreEOL],
+		State:            health.State,
+		TemperatureAlarm: health.TemperatureAlarm,
+	}
+
+	if health.LifeTimeA > 0 {
+		lifeTimeUsed := int(health.LifeTimeA) * 10
+		diskHealth.LifeTimeUsedA = &lifeTimeUsed
+	}
+	if health.LifeTimeB > 0 {
+		lifeTimeUsed := int(health.LifeTimeB) * 10
+		diskHealth.LifeTimeUsedB = &lifeTimeUsed
+	}
+
+	return diskHealth
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+package jobrunner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+
+	"github.com/evilmint/haargos-agent-golang/types"
+)
+
+type AddonOptionsContext struct {
+	Slug    string                 `json:"addon_id"`
+	Options map[string]interface{} `json:"options"`
+}
+
+type AddonConfigurationContext struct {
+	Slug       string  `json:"addon_id"`
+	Boot       *string `json:"boot"`
+	Watchdog   *bool   `json:"watchdog"`
+	AutoUpdate *bool   `json:"auto_update"`
+}
+
+type StoreRepositoryContext struct {
+	Repository string `json:"repository"`
+}
+
+// StoreRepositoryRemoveContext identifies a store repository by the slug the supervisor assigned to it.
+type StoreRepositoryRemoveContext struct {
+	Slug string `json:"slug"`
+}
+
+// Store repository slugs are e.g. core, local or a hash of the repository URL.
+var storeRepositorySlug = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+type supervisorResult struct {
+	Result  string          `json:"result"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+type addonOptionsValidation struct {
+	Valid   bool   `json:"valid"`
+	Message string `json:"message"`
+}
+
+func (j *JobRunner) installAddon(job types.GenericJob, supervisorToken string) (*http.Response, interface{}, error) {
+	var addonContext AddonContext
+	if err := UnmarshalContext(job.Context, &addonContext); err != nil || addonContext.Slug == "" {
+		j.logger.Errorf("Wrong context in job %s", job.Type)
+		return nil, nil, jobFailedError{errors.New("context must contain an addon_id")}
+	}
+
+	j.logger.Infof("Job scheduled [type=%s, slug=%s]", job.Type, addonContext.Slug)
+
+	return j.supervisorPOST(supervisorToken, fmt.Sprintf("store/addons/%s/install", addonContext.Slug), nil)
+}
+
+// setAddonOptions validates the options with the supervisor before applying them.
+func (j *JobRunner) setAddonOptions(job types.GenericJob, supervisorToken string) (*http.Response, interface{}, error) {
+	var optionsContext AddonOptionsContext
+	if err := UnmarshalContext(job.Context, &optionsContext); err != nil || optionsContext.Slug == "" || optionsContext.Options == nil {
+		j.logger.Errorf("Wrong context in job %s", job.Type)
+		return nil, nil, jobFailedError{errors.New("context must contain an addon_id and options")}
+	}
+
+	j.logger.Infof("Job scheduled [type=%s, slug=%s]", job.Type, optionsContext.Slug)
+
+	res, data, err := j.supervisorPOST(supervisorToken, fmt.Sprintf("addons/%s/options/validate", optionsContext.Slug), optionsContext.Options)
+	if err != nil {
+		return res, data, err
+	}
+
+	var validation addonOptionsValidation
+	if err := json.Unmarshal(data.(json.RawMessage), &validation); err != nil {
+		return res, nil, jobFailedError{fmt.Errorf("error unmarshaling options validation: %w", err)}
+	}
+
+	if !validation.Valid {
+		return res, validation, jobFailedError{fmt.Errorf("invalid add-on options: %s", validation.Message)}
+	}
+
+	return j.supervisorPOST(supervisorToken, fmt.Sprintf("addons/%s/options", optionsContext.Slug), map[string]interface{}{
+		"options": optionsContext.Options,
+	})
+}
+
+// configureAddon toggles the boot, watchdog and auto update flags of an add-on.
+func (j *JobRunner) configureAddon(job types.GenericJob, supervisorToken string) (*http.Response, interface{}, error) {
+	var configurationContext AddonConfigurationContext
+	if err := UnmarshalContext(job.Context, &configurationContext); err != nil || configurationContext.Slug == "" {
+		j.logger.Errorf("Wrong context in job %s", job.Type)
+		return nil, nil, jobFailedError{errors.New("context must contain an addon_id")}
+	}
+
+	payload := map[string]interface{}{}
+	if configurationContext.Boot != nil {
+		if *configurationContext.Boot != "auto" && *configurationContext.Boot != "manual" {
+			return nil, nil, jobFailedError{fmt.Errorf("boot must be auto or manual, got %s", *configurationContext.Boot)}
+		}
+		payload["boot"] = *configurationContext.Boot
+	}
+	if configurationContext.Watchdog != nil {
+		payload["watchdog"] = *configurationContext.Watchdog
+	}
+	if configurationContext.AutoUpdate != nil {
+		payload["auto_update"] = *configurationContext.AutoUpdate
+	}
+
+	if len(payload) == 0 {
+		return nil, nil, jobFailedError{errors.New("context must contain boot, watchdog or auto_update")}
+	}
+
+	j.logger.Infof("Job scheduled [type=%s, slug=%s]", job.Type, configurationContext.Slug)
+
+	return j.supervisorPOST(supervisorToken, fmt.Sprintf("addons/%s/options", configurationContext.Slug), payload)
+}
+
+func (j *JobRunner) addStoreRepository(job types.GenericJob, supervisorToken string) (*http.Response, interface{}, error) {
+	var repositoryContext StoreRepositoryContext
+	if err := UnmarshalContext(job.Context, &repositoryContext); err != nil || repositoryContext.Repository == "" {
+		j.logger.Errorf("Wrong context in job %s", job.Type)
+		return nil, nil, jobFailedError{errors.New("context must contain a repository")}
+	}
+
+	j.logger.Infof("Job scheduled [type=%s, repository=%s]", job.Type, repositoryContext.Repository)
+
+	return j.supervisorPOST(supervisorToken, "store/repositories", map[string]interface{}{
+		"repository": repositoryContext.Repository,
+	})
+}
+
+func (j *JobRunner) removeStoreRepository(job types.GenericJob, supervisorToken string) (*http.Response, interface{}, error) {
+	var repositoryContext StoreRepositoryRemoveContext
+	if err := UnmarshalContext(job.Context, &repositoryContext); err != nil || !storeRepositorySlug.MatchString(repositoryContext.Slug) {
+		j.logger.Errorf("Wrong context in job %s", job.Type)
+		return nil, nil, jobFailedError{errors.New("context must contain a repository slug")}
+	}
+
+	j.logger.Infof("Job scheduled [type=%s, slug=%s]", job.Type, repositoryContext.Slug)
+
+	res, err := j.supervisorClient.GenericDELETE(
+		map[string]string{"Authorization": fmt.Sprintf("Bearer %s", supervisorToken)},
+		fmt.Sprintf("store/repositories/%s", url.PathEscape(repositoryContext.Slug)),
+	)
+	return res, nil, err
+}
+
+// supervisorPOST posts the payload to the supervisor and returns the data of its response.
+// Errors include the message the supervisor responded with.
+func (j *JobRunner) supervisorPOST(supervisorToken string, path string, payload interface{}) (*http.Response, interface{}, error) {
+	var response supervisorResult
+	res, err := j.supervisorClient.PostJSON(
+		map[string]string{"Authorization": fmt.Sprintf("Bearer %s", supervisorToken)},
+		path,
+		payload,
+		&response,
+	)
+
+	if err != nil && response.Message != "" {
+		err = fmt.Errorf("%w: %s", err, response.Message)
+	}
+
+	return res, response.Data, err
+}
@@ -0,0 +1,192 @@
+package jobrunner
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/evilmint/haargos-agent-golang/types"
+)
+
+type AuditDecision string
+
+const (
+	AuditDecisionExecuted AuditDecision = "executed"
+	AuditDecisionRejected AuditDecision = "rejected"
+	AuditDecisionExpired  AuditDecision = "expired"
+	AuditDecisionDryRun   AuditDecision = "dry_run"
+	AuditDecisionDeferred AuditDecision = "deferred"
+)
+
+// The outcome of executed jobs which did not reach their target and will be retried.
+const auditOutcomeRetrying = "retrying"
+
+// The outcome of jobs waiting for the maintenance window.
+const auditOutcomePending = "pending"
+
+// AuditEntry records what the agent did with a job it received.
+type AuditEntry struct {
+	JobID      string         `json:"job_id"`
+	Type       string         `json:"type"`
+	Context    interface{}    `json:"context,omitempty"`
+	Decision   AuditDecision  `json:"decision"`
+	Calls      []RecordedCall `json:"calls,omitempty"`
+	Outcome    string         `json:"outcome"`
+	Reason     string         `json:"reason,omitempty"`
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt time.Time      `json:"finished_at"`
+}
+
+// AuditLog is an append-only log of the jobs the agent received, stored as one JSON
+// entry per line. Once the file reaches maxSize it is moved aside, replacing the
+// previously moved file, so at most twice maxSize is kept on disk.
+type AuditLog struct {
+	path    string
+	maxSize int64
+	mutex   sync.Mutex
+}
+
+func NewAuditLog(path string, maxSize int64) *AuditLog {
+	return &AuditLog{path: path, maxSize: maxSize}
+}
+
+func (a *AuditLog) Append(entry AuditEntry) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("Error encoding audit entry: %w", err)
+	}
+
+	if info, err := os.Stat(a.path); err == nil && info.Size()+int64(len(data)) >= a.maxSize {
+		if err := os.Rename(a.path, a.rotatedPath()); err != nil {
+			return fmt.Errorf("Error rotating audit log %s: %w", a.path, err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(a.path), 0755); err != nil {
+		return fmt.Errorf("Error creating audit log directory: %w", err)
+	}
+
+	file, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("Error opening audit log %s: %w", a.path, err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("Error writing audit log %s: %w", a.path, err)
+	}
+
+	return nil
+}
+
+// Recent returns up to limit of the latest entries, newest first.
+func (a *AuditLog) Recent(limit int) ([]AuditEntry, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	var entries []AuditEntry
+	for _, path := range []string{a.rotatedPath(), a.path} {
+		fileEntries, err := readAuditEntries(path)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fileEntries...)
+	}
+
+	if len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+
+	for i, k := 0, len(entries)-1; i < k; i, k = i+1, k-1 {
+		entries[i], entries[k] = entries[k], entries[i]
+	}
+
+	return entries, nil
+}
+
+func (a *AuditLog) rotatedPath() string {
+	return a.path + ".1"
+}
+
+func readAuditEntries(path string) ([]AuditEntry, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error opening audit log %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		var entry AuditEntry
+		// Skip lines which were cut short, e.g. by a full disk.
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err == nil {
+			entries = append(entries, entry)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Error reading audit log %s: %w", path, err)
+	}
+
+	return entries, nil
+}
+
+// audit appends the job to the audit log, with secrets in its context and calls redacted.
+func (j *JobRunner) audit(job types.GenericJob, decision AuditDecision, startedAt time.Time, calls []RecordedCall, outcome string, reason string) {
+	if j.auditLog == nil {
+		return
+	}
+
+	entry := AuditEntry{
+		JobID:      job.ID,
+		Type:       job.Type,
+		Context:    redactedCopy(job.Context),
+		Decision:   decision,
+		Outcome:    outcome,
+		Reason:     reason,
+		StartedAt:  startedAt,
+		FinishedAt: time.Now(),
+	}
+
+	for _, call := range calls {
+		call.Body = redactedCopy(call.Body)
+		entry.Calls = append(entry.Calls, call)
+	}
+
+	if err := j.auditLog.Append(entry); err != nil {
+		j.logger.Errorf("Failed writing job audit log [type=%s, id=%s, err=%s]", job.Type, job.ID, err)
+	}
+}
+
+// redactedCopy returns a copy of the value with sensitive keys redacted, leaving the value itself untouched.
+func redactedCopy(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+
+	var copy interface{}
+	if err := json.Unmarshal(data, &copy); err != nil {
+		return nil
+	}
+
+	return redact(copy)
+}
@@ -0,0 +1,66 @@
+package jobrunner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAuditLog_RecentNewestFirst(t *testing.T) {
+	auditLog := NewAuditLog(filepath.Join(t.TempDir(), "job-audit.log"), 1024*1024)
+
+	for i := 0; i < 3; i++ {
+		if err := auditLog.Append(AuditEntry{JobID: fmt.Sprintf("job-%d", i)}); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	entries, err := auditLog.Recent(2)
+	if err != nil {
+		t.Fatalf("Recent() error = %v", err)
+	}
+
+	if len(entries) != 2 || entries[0].JobID != "job-2" || entries[1].JobID != "job-1" {
+		t.Errorf("Recent() = %v, want job-2 and job-1", entries)
+	}
+}
+
+func TestAuditLog_capsSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "job-audit.log")
+	auditLog := NewAuditLog(path, 512)
+
+	for i := 0; i < 50; i++ {
+		if err := auditLog.Append(AuditEntry{JobID: fmt.Sprintf("job-%d", i), Type: "addon_restart"}); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	for _, file := range []string{path, path + ".1"} {
+		info, err := os.Stat(file)
+		if err != nil {
+			t.Fatalf("Stat(%s) error = %v", file, err)
+		}
+		if info.Size() > 512 {
+			t.Errorf("%s is %d bytes, want at most 512", file, info.Size())
+		}
+	}
+
+	entries, _ := auditLog.Recent(1)
+	if len(entries) != 1 || entries[0].JobID != "job-49" {
+		t.Errorf("Recent() = %v, want job-49", entries)
+	}
+}
+
+func TestAudit_redactsContext(t *testing.T) {
+	context := map[string]interface{}{"addon_id": "core_mosquitto", "options": map[string]interface{}{"password": "secret"}}
+
+	redacted := redactedCopy(context).(map[string]interface{})
+
+	if redacted["options"].(map[string]interface{})["password"] != redactedValue {
+		t.Errorf("redactedCopy() = %v, want the password redacted", redacted)
+	}
+	if context["options"].(map[string]interface{})["password"] != "secret" {
+		t.Errorf("redactedCopy() modified the original context")
+	}
+}
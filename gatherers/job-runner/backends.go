@@ -0,0 +1,173 @@
+package jobrunner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/evilmint/haargos-agent-golang/client"
+	"github.com/evilmint/haargos-agent-golang/types"
+)
+
+const (
+	AgentTypeAddon  = "addon"
+	AgentTypeDocker = "docker"
+	AgentTypeBin    = "bin"
+)
+
+// jobHandlers returns the handlers available for the agent type. Add-on agents act
+// through the supervisor, while the others control Home Assistant core directly.
+func (j *JobRunner) jobHandlers() map[string]jobHandler {
+	var handlers map[string]jobHandler
+
+	switch j.agentType {
+	case AgentTypeDocker:
+		handlers = j.dockerHandlers()
+	case AgentTypeBin:
+		handlers = j.homeAssistantHandlers()
+	default:
+		handlers = j.supervisorHandlers()
+	}
+
+	// These jobs pick the API to use themselves and work for every agent type.
+	handlers["call_service"] = j.callService
+	handlers["core_check_config"] = j.checkConfig
+	handlers["collect_diagnostics"] = j.collectDiagnostics
+
+	return handlers
+}
+
+func (j *JobRunner) supervisorHandlers() map[string]jobHandler {
+	return map[string]jobHandler{
+		"update_core":             j.withConfigCheck(j.supervisorAction(j.updateCore)),
+		"update_addon":            j.supervisorAction(j.updateAddon),
+		"update_os":               j.supervisorAction(j.updateOS),
+		"addon_stop":              j.supervisorAction(j.stopAddon),
+		"addon_start":             j.supervisorAction(j.startAddon),
+		"addon_uninstall":         j.supervisorAction(j.uninstallAddon),
+		"addon_restart":           j.supervisorAction(j.restartAddon),
+		"addon_update":            j.supervisorAction(j.updateAddon),
+		"addon_install":           j.installAddon,
+		"addon_set_options":       j.setAddonOptions,
+		"addon_configure":         j.configureAddon,
+		"store_repository_add":    j.addStoreRepository,
+		"store_repository_remove": j.removeStoreRepository,
+		"supervisor_update":       j.postAction("supervisor/update"),
+		"supervisor_restart":      j.postAction("supervisor/restart"),
+		"supervisor_repair":       j.postAction("supervisor/repair"),
+		"supervisor_reload":       j.postAction("supervisor/reload"),
+		"core_stop":               j.postAction("core/stop"),
+		"core_restart":            j.withConfigCheck(j.postAction("core/restart")),
+		"core_start":              j.postAction("core/start"),
+		"core_update":             j.withConfigCheck(j.postAction("core/update")),
+		"host_reboot":             j.postAction("host/reboot"),
+		"host_shutdown":           j.postAction("host/shutdown"),
+	}
+}
+
+type supervisorHandler func(job types.GenericJob, client *client.HaargosClient, supervisorClient *client.HaargosClient, supervisorToken string) (*http.Response, error)
+
+// supervisorAction adapts a supervisor call, which produces no result data, to a jobHandler.
+func (j *JobRunner) supervisorAction(action supervisorHandler) jobHandler {
+	return func(job types.GenericJob, supervisorToken string) (*http.Response, interface{}, error) {
+		res, err := action(job, j.haargosClient, j.supervisorClient, supervisorToken)
+		return res, nil, err
+	}
+}
+
+func (j *JobRunner) postAction(path string) jobHandler {
+	return j.supervisorAction(func(job types.GenericJob, client *client.HaargosClient, supervisorClient *client.HaargosClient, supervisorToken string) (*http.Response, error) {
+		return j.genericPOSTAction(job, client, supervisorClient, supervisorToken, path)
+	})
+}
+
+// dockerHandlers control the Home Assistant container through the Docker socket.
+func (j *JobRunner) dockerHandlers() map[string]jobHandler {
+	return map[string]jobHandler{
+		"core_stop":    j.containerAction("stop"),
+		"core_restart": j.withConfigCheck(j.containerAction("restart")),
+		"core_start":   j.containerAction("start"),
+	}
+}
+
+func (j *JobRunner) containerAction(action string) jobHandler {
+	return func(job types.GenericJob, supervisorToken string) (*http.Response, interface{}, error) {
+		j.logger.Infof("Job scheduled [type=%s, container=%s]", job.Type, j.docker.container)
+		res, err := j.docker.containerAction(action)
+		return res, nil, err
+	}
+}
+
+// homeAssistantHandlers call Home Assistant services over its REST API. Core cannot
+// be started this way, as the API is gone once it stopped.
+func (j *JobRunner) homeAssistantHandlers() map[string]jobHandler {
+	return map[string]jobHandler{
+		"core_stop":    j.homeAssistantService("homeassistant", "stop"),
+		"core_restart": j.withConfigCheck(j.homeAssistantService("homeassistant", "restart")),
+	}
+}
+
+func (j *JobRunner) homeAssistantService(domain string, service string) jobHandler {
+	return func(job types.GenericJob, supervisorToken string) (*http.Response, interface{}, error) {
+		if j.homeAssistantClient == nil {
+			return nil, nil, jobFailedError{errors.New("Home Assistant access token is not set")}
+		}
+
+		j.logger.Infof("Job scheduled [type=%s, service=%s.%s]", job.Type, domain, service)
+
+		res, err := j.homeAssistantClient.GenericPOST(
+			map[string]string{"Authorization": fmt.Sprintf("Bearer %s", j.homeAssistantToken)},
+			fmt.Sprintf("services/%s/%s", domain, service),
+		)
+		return res, nil, err
+	}
+}
+
+type dockerEngine struct {
+	httpClient *http.Client
+	container  string
+}
+
+func newDockerEngine(socketPath string, container string) *dockerEngine {
+	return &dockerEngine{
+		httpClient: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return net.Dial("unix", socketPath)
+				},
+			},
+			Timeout: 2 * time.Minute,
+		},
+		container: container,
+	}
+}
+
+func (d *dockerEngine) withTransport(transport http.RoundTripper) *dockerEngine {
+	return &dockerEngine{
+		httpClient: &http.Client{Transport: transport, Timeout: d.httpClient.Timeout},
+		container:  d.container,
+	}
+}
+
+func (d *dockerEngine) containerAction(action string) (*http.Response, error) {
+	req, err := http.NewRequest("POST", fmt.Sprintf("http://localhost/containers/%s/%s", d.container, action), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return resp, err
+	}
+	defer resp.Body.Close()
+
+	// 304 means the container already is in the requested state.
+	if (resp.StatusCode < 200 || resp.StatusCode >= 300) && resp.StatusCode != http.StatusNotModified {
+		return resp, fmt.Errorf("received non-OK response status: %s", resp.Status)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,69 @@
+package jobrunner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/evilmint/haargos-agent-golang/types"
+	websocketclient "github.com/evilmint/haargos-agent-golang/websocket-client"
+)
+
+type ServiceCallContext struct {
+	Domain         string                 `json:"domain"`
+	Service        string                 `json:"service"`
+	Target         map[string]interface{} `json:"target"`
+	Data           map[string]interface{} `json:"data"`
+	ReturnResponse bool                   `json:"return_response"`
+}
+
+// callService calls a Home Assistant service over the websocket API and returns its result.
+func (j *JobRunner) callService(job types.GenericJob, supervisorToken string) (*http.Response, interface{}, error) {
+	var serviceContext ServiceCallContext
+	if err := UnmarshalContext(job.Context, &serviceContext); err != nil || serviceContext.Domain == "" || serviceContext.Service == "" {
+		j.logger.Errorf("Wrong context in job %s", job.Type)
+		return nil, nil, jobFailedError{errors.New("context must contain a domain and a service")}
+	}
+
+	if j.homeAssistantToken == "" {
+		return nil, nil, jobFailedError{errors.New("Home Assistant access token is not set")}
+	}
+
+	j.logger.Infof("Job scheduled [type=%s, service=%s.%s]", job.Type, serviceContext.Domain, serviceContext.Service)
+
+	if j.recorder != nil {
+		j.recorder.record(RecordedCall{
+			Method: "call_service",
+			URL:    j.homeAssistantWebsocketURL,
+			Body:   serviceContext,
+		})
+		if j.recorder.simulate {
+			return nil, nil, nil
+		}
+	}
+
+	wsClient := websocketclient.NewWebSocketClient(j.homeAssistantWebsocketURL)
+	result, err := wsClient.CallService(j.homeAssistantToken, websocketclient.WebsocketMessageCallService{
+		Domain:         serviceContext.Domain,
+		Service:        serviceContext.Service,
+		ServiceData:    serviceContext.Data,
+		Target:         serviceContext.Target,
+		ReturnResponse: serviceContext.ReturnResponse,
+	})
+
+	var serviceError *websocketclient.WSAPIError
+	if errors.As(err, &serviceError) {
+		// Home Assistant handled the call and refused it, e.g. because the service does not exist.
+		return nil, nil, jobFailedError{fmt.Errorf("service call failed: %w", err)}
+	}
+	if errors.Is(err, websocketclient.ErrServiceCallUnknown) {
+		// The call was sent, calling the service again could e.g. toggle a light twice.
+		return nil, nil, jobOutcomeUnknownError{err}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return nil, json.RawMessage(result), nil
+}
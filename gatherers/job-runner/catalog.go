@@ -0,0 +1,95 @@
+package jobrunner
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ContextField describes a field of a job's context.
+type ContextField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// JobTypeInfo describes a job type and the context it accepts.
+type JobTypeInfo struct {
+	Type    string         `json:"type"`
+	Context []ContextField `json:"context"`
+}
+
+// jobContexts maps job types to the struct their context is decoded into.
+// Job types missing here take no context besides dry_run.
+var jobContexts = map[string]interface{}{
+	"update_core":             ConfigCheckContext{},
+	"update_addon":            AddonContext{},
+	"addon_stop":              AddonContext{},
+	"addon_start":             AddonContext{},
+	"addon_uninstall":         AddonContext{},
+	"addon_restart":           AddonContext{},
+	"addon_update":            AddonContext{},
+	"addon_install":           AddonContext{},
+	"addon_set_options":       AddonOptionsContext{},
+	"addon_configure":         AddonConfigurationContext{},
+	"store_repository_add":    StoreRepositoryContext{},
+	"store_repository_remove": StoreRepositoryRemoveContext{},
+	"core_restart":            ConfigCheckContext{},
+	"core_update":             ConfigCheckContext{},
+	"call_service":            ServiceCallContext{},
+	"collect_diagnostics":     DiagnosticsContext{},
+}
+
+// JobTypes lists the job types supported by the agent, sorted by name.
+func (j *JobRunner) JobTypes() []JobTypeInfo {
+	var jobTypes []JobTypeInfo
+
+	for jobType := range j.jobHandlers() {
+		var fields []ContextField
+		if context, found := jobContexts[jobType]; found {
+			fields = contextFields(reflect.TypeOf(context))
+		}
+		fields = append(fields, contextFields(reflect.TypeOf(DryRunContext{}))...)
+
+		jobTypes = append(jobTypes, JobTypeInfo{Type: jobType, Context: fields})
+	}
+
+	sort.Slice(jobTypes, func(a, b int) bool {
+		return jobTypes[a].Type < jobTypes[b].Type
+	})
+
+	return jobTypes
+}
+
+func contextFields(contextType reflect.Type) []ContextField {
+	var fields []ContextField
+
+	for i := 0; i < contextType.NumField(); i++ {
+		field := contextType.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		fields = append(fields, ContextField{Name: name, Type: schemaType(field.Type)})
+	}
+
+	return fields
+}
+
+// schemaType returns the JSON type of values decoded into the Go type.
+func schemaType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaType(t.Elem())
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int64, reflect.Float64:
+		return "number"
+	case reflect.Slice:
+		return "array"
+	default:
+		return "object"
+	}
+}
@@ -0,0 +1,111 @@
+package jobrunner
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/evilmint/haargos-agent-golang/types"
+)
+
+// configCheckResponse covers both the supervisor core/check response ("ok"/"error" with a message)
+// and the Home Assistant config/core/check_config response ("valid"/"invalid" with errors).
+type configCheckResponse struct {
+	Result  string  `json:"result"`
+	Message string  `json:"message"`
+	Errors  *string `json:"errors"`
+}
+
+type ConfigCheckResult struct {
+	Valid  bool   `json:"valid"`
+	Errors string `json:"errors,omitempty"`
+}
+
+type ConfigCheckContext struct {
+	CheckConfig bool `json:"check_config"`
+}
+
+func (j *JobRunner) checkConfig(job types.GenericJob, supervisorToken string) (*http.Response, interface{}, error) {
+	j.logger.Infof("Job scheduled [type=%s]", job.Type)
+
+	res, result, err := j.runConfigCheck(supervisorToken)
+	if err != nil {
+		return res, nil, err
+	}
+
+	if !result.Valid {
+		j.logger.Warningf("Configuration check failed: %s", result.Errors)
+	}
+
+	return res, result, nil
+}
+
+// runConfigCheck validates the Home Assistant configuration through the supervisor
+// or, on non-supervised installations, through the Home Assistant REST API.
+func (j *JobRunner) runConfigCheck(supervisorToken string) (*http.Response, *ConfigCheckResult, error) {
+	var response configCheckResponse
+	var res *http.Response
+	var err error
+
+	if j.agentType == AgentTypeAddon {
+		res, err = j.supervisorClient.PostJSON(
+			map[string]string{"Authorization": fmt.Sprintf("Bearer %s", supervisorToken)},
+			"core/check",
+			nil,
+			&response,
+		)
+	} else {
+		if j.homeAssistantClient == nil {
+			return nil, nil, jobFailedError{errors.New("Home Assistant access token is not set")}
+		}
+
+		res, err = j.homeAssistantClient.PostJSON(
+			map[string]string{"Authorization": fmt.Sprintf("Bearer %s", j.homeAssistantToken)},
+			"config/core/check_config",
+			nil,
+			&response,
+		)
+	}
+
+	switch response.Result {
+	case "ok", "valid":
+		return res, &ConfigCheckResult{Valid: true}, nil
+	case "error":
+		return res, &ConfigCheckResult{Valid: false, Errors: response.Message}, nil
+	case "invalid":
+		result := &ConfigCheckResult{Valid: false}
+		if response.Errors != nil {
+			result.Errors = *response.Errors
+		}
+		return res, result, nil
+	}
+
+	if err == nil {
+		err = fmt.Errorf("unexpected configuration check result %q", response.Result)
+	}
+
+	return res, nil, err
+}
+
+// withConfigCheck runs the configuration check before the handler if the job asks
+// for it with check_config, and fails the job without running the handler if it finds errors.
+func (j *JobRunner) withConfigCheck(handler jobHandler) jobHandler {
+	return func(job types.GenericJob, supervisorToken string) (*http.Response, interface{}, error) {
+		var checkContext ConfigCheckContext
+		if err := UnmarshalContext(job.Context, &checkContext); err != nil || !checkContext.CheckConfig {
+			return handler(job, supervisorToken)
+		}
+
+		res, result, err := j.runConfigCheck(supervisorToken)
+		if err != nil {
+			return res, nil, err
+		}
+
+		if !result.Valid {
+			j.logger.Warningf("Job aborted, configuration check failed [type=%s, id=%s]", job.Type, job.ID)
+			return res, result, jobFailedError{fmt.Errorf("configuration check failed: %s", result.Errors)}
+		}
+
+		return handler(job, supervisorToken)
+	}
+}
@@ -0,0 +1,232 @@
+package jobrunner
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/evilmint/haargos-agent-golang/gatherers/loggatherer"
+	"github.com/evilmint/haargos-agent-golang/registry"
+	"github.com/evilmint/haargos-agent-golang/types"
+)
+
+const redactedValue = "**REDACTED**"
+
+// Keys whose values are replaced in the collected diagnostics.
+var sensitiveKeyParts = []string{"password", "token", "secret", "api_key", "apikey", "credential", "private_key", "passphrase"}
+
+var sensitiveLogValue = regexp.MustCompile(`(?i)((?:password|token|secret|api_?key)["']?\s*[:=]\s*["']?)[^\s"',}]+`)
+
+type DiagnosticsContext struct {
+	Domain   string `json:"domain"`
+	DeviceID string `json:"device_id"`
+}
+
+type ConfigEntryDiagnostics struct {
+	EntryID string      `json:"entry_id"`
+	Domain  string      `json:"domain"`
+	Title   string      `json:"title"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// DiagnosticsBundle is the result of a collect_diagnostics job.
+type DiagnosticsBundle struct {
+	Domain      string                       `json:"domain,omitempty"`
+	DeviceID    string                       `json:"device_id,omitempty"`
+	Diagnostics []ConfigEntryDiagnostics     `json:"diagnostics"`
+	Devices     []types.DeviceRegistryDevice `json:"devices"`
+	Entities    []types.EntityRegistryEntity `json:"entities"`
+	Logs        []string                     `json:"logs"`
+}
+
+// collectDiagnostics gathers the diagnostics of an integration or a device together with
+// its registry entries and log lines, so they need not be downloaded by hand.
+func (j *JobRunner) collectDiagnostics(job types.GenericJob, supervisorToken string) (*http.Response, interface{}, error) {
+	var diagnosticsContext DiagnosticsContext
+	if err := UnmarshalContext(job.Context, &diagnosticsContext); err != nil {
+		return nil, nil, jobFailedError{err}
+	}
+	if diagnosticsContext.Domain == "" && diagnosticsContext.DeviceID == "" {
+		return nil, nil, jobFailedError{errors.New("either domain or device_id is required")}
+	}
+
+	j.logger.Infof("Job scheduled [type=%s, domain=%s, device=%s]", job.Type, diagnosticsContext.Domain, diagnosticsContext.DeviceID)
+
+	configEntries, err := registry.ReadConfigEntries(j.haConfigPath)
+	if err != nil {
+		return nil, nil, jobFailedError{err}
+	}
+	deviceRegistry, err := registry.ReadDeviceRegistry(j.logger, j.haConfigPath)
+	if err != nil {
+		return nil, nil, jobFailedError{err}
+	}
+	entityRegistry, err := registry.ReadEntityRegistry(j.haConfigPath)
+	if err != nil {
+		return nil, nil, jobFailedError{err}
+	}
+
+	bundle := DiagnosticsBundle{
+		Domain:      diagnosticsContext.Domain,
+		DeviceID:    diagnosticsContext.DeviceID,
+		Diagnostics: []ConfigEntryDiagnostics{},
+		Devices:     []types.DeviceRegistryDevice{},
+		Entities:    []types.EntityRegistryEntity{},
+	}
+
+	entries := map[string]types.ConfigEntry{}
+	for _, entry := range configEntries.Data.Entries {
+		if diagnosticsContext.Domain == "" || entry.Domain == diagnosticsContext.Domain {
+			entries[entry.EntryID] = entry
+		}
+	}
+
+	if diagnosticsContext.DeviceID != "" {
+		device := findDevice(deviceRegistry.Data.Devices, diagnosticsContext.DeviceID)
+		if device == nil {
+			return nil, nil, jobFailedError{fmt.Errorf("device %s not found", diagnosticsContext.DeviceID)}
+		}
+		bundle.Devices = append(bundle.Devices, *device)
+
+		// Only the entries the device belongs to have diagnostics for it.
+		deviceEntries := map[string]types.ConfigEntry{}
+		for _, entryID := range device.ConfigEntries {
+			if entry, found := entries[entryID]; found {
+				deviceEntries[entryID] = entry
+			}
+		}
+		entries = deviceEntries
+	} else {
+		for _, device := range deviceRegistry.Data.Devices {
+			for _, entryID := range device.ConfigEntries {
+				if _, found := entries[entryID]; found {
+					bundle.Devices = append(bundle.Devices, device)
+					break
+				}
+			}
+		}
+	}
+
+	for _, entity := range entityRegistry.Data.Entities {
+		if belongsToBundle(entity, bundle) {
+			bundle.Entities = append(bundle.Entities, entity)
+		}
+	}
+
+	var domains []string
+	for _, entry := range configEntries.Data.Entries {
+		if _, found := entries[entry.EntryID]; !found {
+			continue
+		}
+
+		bundle.Diagnostics = append(bundle.Diagnostics, j.configEntryDiagnostics(entry, diagnosticsContext.DeviceID, supervisorToken))
+		if !contains(domains, entry.Domain) {
+			domains = append(domains, entry.Domain)
+		}
+	}
+
+	logGatherer := loggatherer.NewLogGatherer(j.logger)
+	bundle.Logs = []string{}
+	for _, domain := range domains {
+		for _, line := range logGatherer.GatherIntegrationLogs(j.haConfigPath, domain) {
+			bundle.Logs = append(bundle.Logs, redactLogLine(line))
+		}
+	}
+
+	return nil, bundle, nil
+}
+
+// configEntryDiagnostics downloads the diagnostics of a config entry, or of one of its devices.
+// Failures are recorded in the result, as not every integration provides diagnostics.
+func (j *JobRunner) configEntryDiagnostics(entry types.ConfigEntry, deviceID string, supervisorToken string) ConfigEntryDiagnostics {
+	diagnostics := ConfigEntryDiagnostics{EntryID: entry.EntryID, Domain: entry.Domain, Title: entry.Title}
+
+	path := fmt.Sprintf("diagnostics/config_entry/%s", entry.EntryID)
+	if deviceID != "" {
+		path = fmt.Sprintf("%s/device/%s", path, deviceID)
+	}
+
+	var data interface{}
+	if err := j.homeAssistantGET(path, supervisorToken, &data); err != nil {
+		j.logger.Warningf("Failed fetching diagnostics [entry=%s, err=%s]", entry.EntryID, err)
+		diagnostics.Error = err.Error()
+		return diagnostics
+	}
+
+	diagnostics.Data = redact(data)
+	return diagnostics
+}
+
+// homeAssistantGET fetches a path of the Home Assistant REST API, proxied by the supervisor on add-on agents.
+func (j *JobRunner) homeAssistantGET(path string, supervisorToken string, target interface{}) error {
+	if j.agentType == AgentTypeAddon {
+		_, err := j.supervisorClient.GetJSON(
+			map[string]string{"Authorization": fmt.Sprintf("Bearer %s", supervisorToken)},
+			"core/api/"+path,
+			target,
+		)
+		return err
+	}
+
+	if j.homeAssistantClient == nil {
+		return errors.New("Home Assistant access token is not set")
+	}
+
+	_, err := j.homeAssistantClient.GetJSON(
+		map[string]string{"Authorization": fmt.Sprintf("Bearer %s", j.homeAssistantToken)},
+		path,
+		target,
+	)
+	return err
+}
+
+func findDevice(devices []types.DeviceRegistryDevice, deviceID string) *types.DeviceRegistryDevice {
+	for i := range devices {
+		if devices[i].ID == deviceID {
+			return &devices[i]
+		}
+	}
+	return nil
+}
+
+func belongsToBundle(entity types.EntityRegistryEntity, bundle DiagnosticsBundle) bool {
+	if bundle.DeviceID != "" {
+		return entity.DeviceID != nil && *entity.DeviceID == bundle.DeviceID
+	}
+	return entity.Platform == bundle.Domain
+}
+
+// redact replaces the values of sensitive keys in decoded JSON.
+func redact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, nested := range v {
+			if isSensitiveKey(key) {
+				v[key] = redactedValue
+			} else {
+				v[key] = redact(nested)
+			}
+		}
+	case []interface{}:
+		for i, nested := range v {
+			v[i] = redact(nested)
+		}
+	}
+	return value
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactLogLine(line string) string {
+	return sensitiveLogValue.ReplaceAllString(line, "${1}"+redactedValue)
+}
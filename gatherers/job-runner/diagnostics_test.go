@@ -0,0 +1,54 @@
+package jobrunner
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	data := map[string]interface{}{
+		"host":         "192.168.1.10",
+		"access_token": "abc",
+		"nested": []interface{}{
+			map[string]interface{}{"Password": "hunter2", "port": 1883.0},
+		},
+	}
+
+	want := map[string]interface{}{
+		"host":         "192.168.1.10",
+		"access_token": redactedValue,
+		"nested": []interface{}{
+			map[string]interface{}{"Password": redactedValue, "port": 1883.0},
+		},
+	}
+
+	if got := redact(data); !reflect.DeepEqual(got, want) {
+		t.Errorf("redact() = %v, want %v", got, want)
+	}
+}
+
+func TestRedactLogLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{
+			line: "ERROR (MainThread) [homeassistant.components.mqtt] Login failed password=hunter2 host=broker",
+			want: "ERROR (MainThread) [homeassistant.components.mqtt] Login failed password=" + redactedValue + " host=broker",
+		},
+		{
+			line: `WARNING [custom_components.foo] Bad response {"token": "abc123"}`,
+			want: `WARNING [custom_components.foo] Bad response {"token": "` + redactedValue + `"}`,
+		},
+		{
+			line: "WARNING [homeassistant.components.mqtt] Disconnected",
+			want: "WARNING [homeassistant.components.mqtt] Disconnected",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := redactLogLine(tt.line); got != tt.want {
+			t.Errorf("redactLogLine(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
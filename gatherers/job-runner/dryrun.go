@@ -0,0 +1,61 @@
+package jobrunner
+
+import (
+	"time"
+
+	"github.com/evilmint/haargos-agent-golang/types"
+)
+
+type DryRunContext struct {
+	DryRun bool `json:"dry_run"`
+}
+
+// DryRunResult is reported for simulated jobs instead of the real result.
+type DryRunResult struct {
+	DryRun bool           `json:"dry_run"`
+	Calls  []RecordedCall `json:"calls"`
+	Data   interface{}    `json:"data,omitempty"`
+}
+
+func (j *JobRunner) isDryRun(job types.GenericJob) bool {
+	if j.dryRun {
+		return true
+	}
+
+	var dryRunContext DryRunContext
+	return UnmarshalContext(job.Context, &dryRunContext) == nil && dryRunContext.DryRun
+}
+
+// simulateJob reports the calls the job would make, without performing any of them.
+func (j *JobRunner) simulateJob(job types.GenericJob, supervisorToken string, startedAt time.Time) {
+	result, calls := j.dryRunResult(job, supervisorToken)
+
+	j.setJobResult(job, JobStateExecuted, result)
+	j.audit(job, AuditDecisionDryRun, startedAt, calls, result.Status, result.Reason)
+	j.reportJob(job, result)
+}
+
+// dryRunResult runs the job's handler against simulating clients and returns the calls it made.
+func (j *JobRunner) dryRunResult(job types.GenericJob, supervisorToken string) (types.JobResult, []RecordedCall) {
+	recorder := &callRecorder{simulate: true}
+	handler := j.recordingRunner(recorder).jobHandlers()[job.Type]
+
+	j.logger.Infof("Job dry run [type=%s, id=%s]", job.Type, job.ID)
+	_, data, err := handler(job, supervisorToken)
+
+	calls := recorder.Calls()
+	for _, call := range calls {
+		j.logger.Infof("Job dry run call [type=%s, id=%s, method=%s, url=%s, body=%v]", job.Type, job.ID, call.Method, call.URL, call.Body)
+	}
+
+	result := types.JobResult{
+		Status: types.JobStatusCompleted,
+		Data:   DryRunResult{DryRun: true, Calls: calls, Data: data},
+	}
+	if err != nil {
+		result.Status = types.JobStatusFailed
+		result.Reason = err.Error()
+	}
+
+	return result, calls
+}
@@ -1,9 +1,13 @@
 package jobrunner
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/evilmint/haargos-agent-golang/client"
 	"github.com/evilmint/haargos-agent-golang/statistics"
@@ -17,16 +21,140 @@ type JobRunner struct {
 	supervisorClient *client.HaargosClient
 	logger           *logrus.Logger
 	statistics       *statistics.Statistics
-	lock             *semaphore.Weighted
+	journal          *JobJournal
+	auditLog         *AuditLog
+	policy           JobPolicy
+	verifier         *JobVerifier
+	schedule         Schedule
+	agentType        string
+	haConfigPath     string
+	docker           *dockerEngine
+	// homeAssistantClient talks to the Home Assistant REST API on non-supervised installations.
+	homeAssistantClient *client.HaargosClient
+	homeAssistantToken  string
+	// homeAssistantWebsocketURL is used for jobs calling Home Assistant services.
+	homeAssistantWebsocketURL string
+	// workers bounds the number of jobs executing at the same time.
+	workers   *semaphore.Weighted
+	resources *resourceQueue
+	inFlight  map[string]bool
+	// deferred holds the jobs waiting for the maintenance window, so each deferral is audited once.
+	deferred map[string]bool
+	// inFlightMutex guards inFlight and deferred.
+	inFlightMutex sync.Mutex
+	// dryRun simulates every job, see simulateJob.
+	dryRun bool
+	// recorder is set on runners recording the calls of a job, see recordingRunner.
+	recorder *callRecorder
+	lock     *semaphore.Weighted
 }
 
-func NewJobRunner(logger *logrus.Logger, haargosClient *client.HaargosClient, supervisorClient *client.HaargosClient, statistics *statistics.Statistics) *JobRunner {
+type Config struct {
+	// JournalPath is the file where the state of received jobs is persisted.
+	JournalPath string
+	// AuditLog records every received job, nil disables it.
+	AuditLog *AuditLog
+	// Policy restricts which jobs the agent executes.
+	Policy JobPolicy
+	// PublicKey is the base64 encoded Ed25519 key jobs must be signed with.
+	// Signature verification is disabled when it is empty.
+	PublicKey string
+	// InstallationID is the installation signed jobs must be issued for.
+	InstallationID string
+	// Schedule defers jobs outside of the maintenance window.
+	Schedule Schedule
+	// AgentType selects how jobs are executed, see jobHandlers.
+	AgentType string
+	// HaConfigPath is the Home Assistant configuration directory jobs read registries and logs from.
+	HaConfigPath string
+	// HomeAssistantClient and HomeAssistantToken are used by bin agents.
+	HomeAssistantClient *client.HaargosClient
+	HomeAssistantToken  string
+	// HomeAssistantWebsocketURL is the websocket API used by call_service jobs.
+	HomeAssistantWebsocketURL string
+	// DockerSocketPath and HomeAssistantContainer are used by docker agents.
+	DockerSocketPath       string
+	HomeAssistantContainer string
+	// MaxConcurrentJobs is the number of independent jobs which may run in parallel.
+	MaxConcurrentJobs int
+	// DryRun simulates every job instead of executing it.
+	DryRun bool
+}
+
+// jobHandler executes a job. It returns the response of the HTTP call performing the
+// action, if there was one, and data to include in the job result.
+type jobHandler func(job types.GenericJob, supervisorToken string) (*http.Response, interface{}, error)
+
+// jobFailedError marks failures which would not change if the job was retried.
+type jobFailedError struct {
+	err error
+}
+
+func (e jobFailedError) Error() string {
+	return e.err.Error()
+}
+
+func (e jobFailedError) Unwrap() error {
+	return e.err
+}
+
+// jobOutcomeUnknownError marks failures after the action was sent, when it may or may not
+// have taken effect. Like jobFailedError, the job is not retried.
+type jobOutcomeUnknownError struct {
+	err error
+}
+
+func (e jobOutcomeUnknownError) Error() string {
+	return e.err.Error()
+}
+
+func (e jobOutcomeUnknownError) Unwrap() error {
+	return e.err
+}
+
+func NewJobRunner(logger *logrus.Logger, haargosClient *client.HaargosClient, supervisorClient *client.HaargosClient, statistics *statistics.Statistics, config Config) *JobRunner {
+	journal, err := NewJobJournal(config.JournalPath)
+	if err != nil {
+		logger.Errorf("Failed loading job journal, starting with an empty one: %s", err)
+	}
+
+	var verifier *JobVerifier
+	if config.PublicKey != "" {
+		verifier, err = NewJobVerifier(config.PublicKey, config.InstallationID)
+		if err != nil {
+			logger.Fatalf("Invalid job public key: %s", err)
+		}
+	} else {
+		logger.Warning("Job public key is not set, job signatures will not be verified.")
+	}
+
+	maxConcurrentJobs := config.MaxConcurrentJobs
+	if maxConcurrentJobs < 1 {
+		maxConcurrentJobs = 1
+	}
+
 	return &JobRunner{
-		haargosClient:    haargosClient,
-		supervisorClient: supervisorClient,
-		logger:           logger,
-		statistics:       statistics,
-		lock:             semaphore.NewWeighted(1),
+		haargosClient:             haargosClient,
+		supervisorClient:          supervisorClient,
+		logger:                    logger,
+		statistics:                statistics,
+		journal:                   journal,
+		policy:                    config.Policy,
+		verifier:                  verifier,
+		schedule:                  config.Schedule,
+		agentType:                 config.AgentType,
+		docker:                    newDockerEngine(config.DockerSocketPath, config.HomeAssistantContainer),
+		homeAssistantClient:       config.HomeAssistantClient,
+		homeAssistantToken:        config.HomeAssistantToken,
+		homeAssistantWebsocketURL: config.HomeAssistantWebsocketURL,
+		workers:                   semaphore.NewWeighted(int64(maxConcurrentJobs)),
+		resources:                 newResourceQueue(),
+		inFlight:                  make(map[string]bool),
+		deferred:                  make(map[string]bool),
+		dryRun:                    config.DryRun,
+		auditLog:                  config.AuditLog,
+		haConfigPath:              config.HaConfigPath,
+		lock:                      semaphore.NewWeighted(1),
 	}
 }
 
@@ -47,144 +175,327 @@ func (j *JobRunner) HandleJobs(haConfigPath string, supervisorToken string) {
 		j.logger.Infof("Collected %d jobs. %s", len(*jobs), jobNames)
 
 		for _, job := range *jobs {
-			if job.Type == "update_core" {
-				j.updateCore(job, j.haargosClient, j.supervisorClient, supervisorToken)
-			} else if job.Type == "update_addon" {
-				j.updateAddon(job, j.haargosClient, j.supervisorClient, supervisorToken)
-			} else if job.Type == "update_os" {
-				j.updateOS(job, j.haargosClient, j.supervisorClient, supervisorToken)
-			} else if job.Type == "addon_stop" {
-				j.stopAddon(job, j.haargosClient, j.supervisorClient, supervisorToken)
-			} else if job.Type == "addon_start" {
-				j.startAddon(job, j.haargosClient, j.supervisorClient, supervisorToken)
-			} else if job.Type == "addon_uninstall" {
-				j.uninstallAddon(job, j.haargosClient, j.supervisorClient, supervisorToken)
-			} else if job.Type == "addon_restart" {
-				j.restartAddon(job, j.haargosClient, j.supervisorClient, supervisorToken)
-			} else if job.Type == "addon_update" {
-				j.updateAddon(job, j.haargosClient, j.supervisorClient, supervisorToken)
-			} else if job.Type == "supervisor_update" {
-				j.genericPOSTAction(job, j.haargosClient, j.supervisorClient, supervisorToken, "supervisor/update")
-			} else if job.Type == "supervisor_restart" {
-				j.genericPOSTAction(job, j.haargosClient, j.supervisorClient, supervisorToken, "supervisor/restart")
-			} else if job.Type == "supervisor_repair" {
-				j.genericPOSTAction(job, j.haargosClient, j.supervisorClient, supervisorToken, "supervisor/repair")
-			} else if job.Type == "supervisor_reload" {
-				j.genericPOSTAction(job, j.haargosClient, j.supervisorClient, supervisorToken, "supervisor/reload")
-			} else if job.Type == "core_stop" {
-				j.genericPOSTAction(job, j.haargosClient, j.supervisorClient, supervisorToken, "core/stop")
-			} else if job.Type == "core_restart" {
-				j.genericPOSTAction(job, j.haargosClient, j.supervisorClient, supervisorToken, "core/restart")
-			} else if job.Type == "core_start" {
-				j.genericPOSTAction(job, j.haargosClient, j.supervisorClient, supervisorToken, "core/start")
-			} else if job.Type == "core_update" {
-				j.genericPOSTAction(job, j.haargosClient, j.supervisorClient, supervisorToken, "core/update")
-			} else if job.Type == "host_reboot" {
-				j.genericPOSTAction(job, j.haargosClient, j.supervisorClient, supervisorToken, "host/reboot")
-			} else if job.Type == "host_shutdown" {
-				j.genericPOSTAction(job, j.haargosClient, j.supervisorClient, supervisorToken, "host/shutdown")
-			} else {
-				j.logger.Warningf("Unsupported job encountered [type=%s]", job.Type)
-			}
-
-			j.statistics.IncrementJobsProcessedCount()
+			j.DispatchJob(job, supervisorToken)
 		}
 	}
 
 	if err != nil {
 		j.statistics.IncrementFailedRequestCount()
 	}
+
+	if err := j.journal.Prune(); err != nil {
+		j.logger.Errorf("Failed pruning job journal: %s", err)
+	}
 }
 
-func (j *JobRunner) stopAddon(job types.GenericJob, client *client.HaargosClient, supervisorClient *client.HaargosClient, supervisorToken string) {
-	j.genericJobPOSTAction(job, client, supervisorClient, supervisorToken, "addons/%s/stop")
+// DispatchJob runs the job in the background once the jobs before it on the same
+// resource have finished and a worker is free. Jobs still running are not dispatched again.
+func (j *JobRunner) DispatchJob(job types.GenericJob, supervisorToken string) {
+	j.inFlightMutex.Lock()
+	if j.inFlight[job.ID] {
+		j.inFlightMutex.Unlock()
+		j.logger.Debugf("Job is already running [type=%s, id=%s]", job.Type, job.ID)
+		return
+	}
+	j.inFlight[job.ID] = true
+	j.inFlightMutex.Unlock()
+
+	resource, exclusive := jobResource(job)
+	wait, done := j.resources.enqueue(resource, exclusive)
+
+	go func() {
+		defer func() {
+			close(done)
+
+			j.inFlightMutex.Lock()
+			delete(j.inFlight, job.ID)
+			j.inFlightMutex.Unlock()
+		}()
+
+		for _, previous := range wait {
+			<-previous
+		}
+
+		if err := j.workers.Acquire(context.Background(), 1); err != nil {
+			j.logger.Errorf("Failed acquiring job worker [type=%s, id=%s, err=%s]", job.Type, job.ID, err)
+			return
+		}
+		defer j.workers.Release(1)
+
+		j.processJob(job, supervisorToken)
+		j.statistics.IncrementJobsProcessedCount()
+	}()
 }
 
-func (j *JobRunner) restartAddon(job types.GenericJob, client *client.HaargosClient, supervisorClient *client.HaargosClient, supervisorToken string) {
-	j.genericJobPOSTAction(job, client, supervisorClient, supervisorToken, "addons/%s/restart")
+func (j *JobRunner) processJob(job types.GenericJob, supervisorToken string) {
+	startedAt := time.Now()
+
+	// Jobs which already ran are only reported, so they are looked up before the signature
+	// is verified: a report retried after the signature expired must not turn into a rejection.
+	entry, found := j.journal.Get(job.ID)
+	if found && entry.State != JobStateReceived {
+		// The agent stopped before the job's result was known, so we cannot tell whether it took effect.
+		// Executing it again could e.g. trigger a second update, so only report it.
+		result := types.JobResult{Status: types.JobStatusUnknown, Reason: "agent stopped while the job was running"}
+		if entry.Result != nil {
+			result = *entry.Result
+		}
+
+		if entry.State == JobStateExecuting {
+			j.logger.Warningf("Job was interrupted during execution, not executing again [type=%s, id=%s]", job.Type, job.ID)
+			j.setJobResult(job, JobStateExecuted, result)
+		} else {
+			j.logger.Infof("Job already executed, retrying report [type=%s, id=%s, status=%s]", job.Type, job.ID, result.Status)
+		}
+
+		j.reportJob(job, result)
+		return
+	}
+
+	if j.verifier != nil {
+		if err := j.verifier.Verify(job, time.Now()); err != nil {
+			j.rejectJob(job, err.Error())
+			return
+		}
+	}
+
+	if !found {
+		j.setJobState(job, JobStateReceived)
+	}
+
+	recorder := &callRecorder{}
+	handler, supported := j.recordingRunner(recorder).jobHandlers()[job.Type]
+	if !supported {
+		if _, known := j.supervisorHandlers()[job.Type]; known {
+			j.rejectJob(job, fmt.Sprintf("job type %s is not supported on %s agents", job.Type, j.agentType))
+			return
+		}
+
+		j.rejectJob(job, fmt.Sprintf("job type %s is not supported", job.Type))
+		return
+	}
+
+	if err := j.policy.Check(job); err != nil {
+		j.rejectJob(job, err.Error())
+		return
+	}
+
+	decision, reason := j.schedule.Check(job, time.Now())
+	if j.setDeferred(job, decision == ScheduleDefer) {
+		j.logger.Infof("Job deferred [type=%s, id=%s, reason=%s]", job.Type, job.ID, reason)
+		j.audit(job, AuditDecisionDeferred, startedAt, nil, auditOutcomePending, reason)
+	}
+
+	switch decision {
+	case ScheduleDefer:
+		return
+	case ScheduleExpire:
+		j.expireJob(job, reason)
+		return
+	}
+
+	if j.isDryRun(job) {
+		j.simulateJob(job, supervisorToken, startedAt)
+		return
+	}
+
+	tracking := j.startTracking(job, supervisorToken)
+	j.setJobState(job, JobStateExecuting)
+	res, data, err := handler(job, supervisorToken)
+	j.logJobFailure(res, err, job)
+
+	var failed jobFailedError
+	var unknown jobOutcomeUnknownError
+	if err != nil && !errors.As(err, &failed) && !errors.As(err, &unknown) && (res == nil || res.StatusCode < 200 || res.StatusCode >= 500) {
+		// The action did not reach its target, allow it to be retried on the next run.
+		j.setJobState(job, JobStateReceived)
+		j.audit(job, AuditDecisionExecuted, startedAt, recorder.Calls(), auditOutcomeRetrying, err.Error())
+		return
+	}
+
+	j.setJobState(job, JobStateExecuted)
+
+	result := j.jobResult(job, tracking, data, err, supervisorToken)
+	j.setJobResult(job, JobStateExecuted, result)
+	j.audit(job, AuditDecisionExecuted, startedAt, recorder.Calls(), result.Status, result.Reason)
+	j.reportJob(job, result)
+}
+
+// setDeferred records whether the job waits for the maintenance window and returns
+// true when it was not waiting before.
+func (j *JobRunner) setDeferred(job types.GenericJob, deferred bool) bool {
+	j.inFlightMutex.Lock()
+	defer j.inFlightMutex.Unlock()
+
+	if !deferred {
+		delete(j.deferred, job.ID)
+		return false
+	}
+
+	wasDeferred := j.deferred[job.ID]
+	j.deferred[job.ID] = true
+
+	return !wasDeferred
+}
+
+// RunJob executes a job issued locally, e.g. from the command line, and returns its result.
+// The signature, journal, policy and schedule only guard jobs issued by the backend and are skipped.
+func (j *JobRunner) RunJob(job types.GenericJob, supervisorToken string) (types.JobResult, error) {
+	handler, supported := j.jobHandlers()[job.Type]
+	if !supported {
+		return types.JobResult{}, fmt.Errorf("job type %s is not supported on %s agents", job.Type, j.agentType)
+	}
+
+	if j.isDryRun(job) {
+		result, _ := j.dryRunResult(job, supervisorToken)
+		return result, nil
+	}
+
+	tracking := j.startTracking(job, supervisorToken)
+	res, data, err := handler(job, supervisorToken)
+	j.logJobFailure(res, err, job)
+
+	return j.jobResult(job, tracking, data, err, supervisorToken), nil
+}
+
+// jobResult returns the result of an executed job, waiting for tracked updates to finish.
+// tracking is nil for jobs which are not tracked, see startTracking.
+func (j *JobRunner) jobResult(job types.GenericJob, tracking *updateTracking, data interface{}, err error, supervisorToken string) types.JobResult {
+	var unknown jobOutcomeUnknownError
+	if errors.As(err, &unknown) {
+		return types.JobResult{Status: types.JobStatusUnknown, Reason: err.Error(), Data: data}
+	}
+
+	if err != nil {
+		// The action was refused, retrying would not change the outcome.
+		return types.JobResult{Status: types.JobStatusFailed, Reason: err.Error(), Data: data}
+	}
+
+	if tracking != nil {
+		return j.trackUpdate(job, tracking, supervisorToken)
+	}
+
+	return types.JobResult{Status: types.JobStatusCompleted, Data: data}
+}
+
+// expireJob reports a job which missed its deadline back to the backend.
+func (j *JobRunner) expireJob(job types.GenericJob, reason string) {
+	j.logger.Warningf("Job expired [type=%s, id=%s, reason=%s]", job.Type, job.ID, reason)
+	j.audit(job, AuditDecisionExpired, time.Now(), nil, types.JobStatusExpired, reason)
+
+	err := j.haargosClient.CompleteJob(job, types.JobResult{Status: types.JobStatusExpired, Reason: reason})
+	if err != nil {
+		j.logger.Errorf("Job expiry report failed [type=%s, id=%s, err=%s]", job.Type, job.ID, err)
+	}
+}
+
+// rejectJob reports a job which the agent refuses to execute back to the backend.
+func (j *JobRunner) rejectJob(job types.GenericJob, reason string) {
+	j.logger.Warningf("Job rejected [type=%s, id=%s, context=%v, reason=%s]", job.Type, job.ID, job.Context, reason)
+	j.audit(job, AuditDecisionRejected, time.Now(), nil, types.JobStatusRejected, reason)
+
+	err := j.haargosClient.CompleteJob(job, types.JobResult{Status: types.JobStatusRejected, Reason: reason})
+	if err != nil {
+		j.logger.Errorf("Job rejection report failed [type=%s, id=%s, err=%s]", job.Type, job.ID, err)
+	}
+}
+
+func (j *JobRunner) setJobState(job types.GenericJob, state JobState) {
+	if err := j.journal.SetState(job, state); err != nil {
+		j.logger.Errorf("Failed updating job journal [type=%s, id=%s, state=%s, err=%s]", job.Type, job.ID, state, err)
+	}
+}
+
+func (j *JobRunner) setJobResult(job types.GenericJob, state JobState, result types.JobResult) {
+	if err := j.journal.SetResult(job, state, result); err != nil {
+		j.logger.Errorf("Failed updating job journal [type=%s, id=%s, state=%s, err=%s]", job.Type, job.ID, state, err)
+	}
 }
 
-func (j *JobRunner) startAddon(job types.GenericJob, client *client.HaargosClient, supervisorClient *client.HaargosClient, supervisorToken string) {
-	j.genericJobPOSTAction(job, client, supervisorClient, supervisorToken, "addons/%s/start")
+func (j *JobRunner) stopAddon(job types.GenericJob, client *client.HaargosClient, supervisorClient *client.HaargosClient, supervisorToken string) (*http.Response, error) {
+	return j.genericJobPOSTAction(job, client, supervisorClient, supervisorToken, "addons/%s/stop")
 }
 
-func (j *JobRunner) uninstallAddon(job types.GenericJob, client *client.HaargosClient, supervisorClient *client.HaargosClient, supervisorToken string) {
-	j.genericJobPOSTAction(job, client, supervisorClient, supervisorToken, "addons/%s/uninstall")
+func (j *JobRunner) restartAddon(job types.GenericJob, client *client.HaargosClient, supervisorClient *client.HaargosClient, supervisorToken string) (*http.Response, error) {
+	return j.genericJobPOSTAction(job, client, supervisorClient, supervisorToken, "addons/%s/restart")
 }
 
-func (j *JobRunner) updateAddon(job types.GenericJob, client *client.HaargosClient, supervisorClient *client.HaargosClient, supervisorToken string) {
-	j.genericJobPOSTAction(job, client, supervisorClient, supervisorToken, "addons/%s/update")
+func (j *JobRunner) startAddon(job types.GenericJob, client *client.HaargosClient, supervisorClient *client.HaargosClient, supervisorToken string) (*http.Response, error) {
+	return j.genericJobPOSTAction(job, client, supervisorClient, supervisorToken, "addons/%s/start")
+}
+
+func (j *JobRunner) uninstallAddon(job types.GenericJob, client *client.HaargosClient, supervisorClient *client.HaargosClient, supervisorToken string) (*http.Response, error) {
+	return j.genericJobPOSTAction(job, client, supervisorClient, supervisorToken, "addons/%s/uninstall")
+}
+
+func (j *JobRunner) updateAddon(job types.GenericJob, client *client.HaargosClient, supervisorClient *client.HaargosClient, supervisorToken string) (*http.Response, error) {
+	return j.genericJobPOSTAction(job, client, supervisorClient, supervisorToken, "addons/%s/update")
 }
 
 type AddonContext struct {
 	Slug string `json:"addon_id"`
 }
 
-func (j *JobRunner) genericJobPOSTAction(job types.GenericJob, client *client.HaargosClient, supervisorClient *client.HaargosClient, supervisorToken string, pathWithSlug string) {
+func (j *JobRunner) genericJobPOSTAction(job types.GenericJob, client *client.HaargosClient, supervisorClient *client.HaargosClient, supervisorToken string, pathWithSlug string) (*http.Response, error) {
 	var addonContext AddonContext
 	if err := UnmarshalContext(job.Context, &addonContext); err != nil {
 		j.logger.Errorf("Wrong context in job %s", job.Type)
-		return
+		return nil, jobFailedError{err}
 	}
 
 	j.logger.Infof("Job scheduled [type=%s, slug=%s]", job.Type, addonContext.Slug)
 
-	res, err := supervisorClient.GenericPOST(
+	return supervisorClient.GenericPOST(
 		map[string]string{"Authorization": fmt.Sprintf("Bearer %s", supervisorToken)},
 		fmt.Sprintf(pathWithSlug, addonContext.Slug),
 	)
-
-	j.finalizeUpdate(res, err, addonContext, job, client)
 }
 
-func (j *JobRunner) genericPOSTAction(job types.GenericJob, client *client.HaargosClient, supervisorClient *client.HaargosClient, supervisorToken string, pathWithSlug string) {
+func (j *JobRunner) genericPOSTAction(job types.GenericJob, client *client.HaargosClient, supervisorClient *client.HaargosClient, supervisorToken string, path string) (*http.Response, error) {
 	j.logger.Infof("Job scheduled [type=%s]", job.Type)
 
-	res, err := supervisorClient.GenericPOST(
+	return supervisorClient.GenericPOST(
 		map[string]string{"Authorization": fmt.Sprintf("Bearer %s", supervisorToken)},
-		fmt.Sprintf(pathWithSlug),
+		path,
 	)
-
-	j.finalizeUpdate(res, err, nil, job, client)
 }
 
-func (j *JobRunner) updateOS(job types.GenericJob, client *client.HaargosClient, supervisorClient *client.HaargosClient, supervisorToken string) {
+func (j *JobRunner) updateOS(job types.GenericJob, client *client.HaargosClient, supervisorClient *client.HaargosClient, supervisorToken string) (*http.Response, error) {
 	j.logger.Infof("Job scheduled [type=%s]", job.Type)
 
-	res, err := supervisorClient.UpdateOS(map[string]string{"Authorization": fmt.Sprintf("Bearer %s", supervisorToken)})
-
-	j.finalizeUpdate(res, err, "", job, client)
+	return supervisorClient.UpdateOS(map[string]string{"Authorization": fmt.Sprintf("Bearer %s", supervisorToken)})
 }
 
-func (j *JobRunner) finalizeUpdate(res *http.Response, err error, context interface{}, job types.GenericJob, client *client.HaargosClient) {
-	if err != nil {
-		resString := ""
+func (j *JobRunner) logJobFailure(res *http.Response, err error, job types.GenericJob) {
+	if err == nil {
+		return
+	}
 
-		if res != nil && res.StatusCode >= 200 && res.StatusCode < 300 {
-			resString += fmt.Sprintf(", status=%s", res.Status)
-		}
-		if res != nil {
-			j.logger.Infof("Res is not nil [status=%s]", res.Status)
-		} else {
-			j.logger.Infof("Res is nil")
-		}
+	resString := ""
 
-		j.logger.Errorf("Job failure [type=%s, context=%s, err=%s%s]", job.Type, context, err, resString)
+	if res != nil && res.StatusCode >= 200 && res.StatusCode < 300 {
+		resString += fmt.Sprintf(", status=%s", res.Status)
+	}
+	if res != nil {
+		j.logger.Infof("Res is not nil [status=%s]", res.Status)
+	} else {
+		j.logger.Infof("Res is nil")
 	}
 
-	if res != nil && (res.StatusCode < 500 && res.StatusCode >= 200) {
-		err = client.CompleteJob(job)
+	j.logger.Errorf("Job failure [type=%s, context=%v, err=%s%s]", job.Type, job.Context, err, resString)
+}
 
-		if err != nil {
-			if context != nil {
-				j.logger.Errorf("Job dequeue failed [type=%s, context=%s, err=%s]", job.Type, context, err)
-			} else {
-				j.logger.Errorf("Job dequeue failed [type=%s, err=%s]", job.Type, err)
-			}
-		} else {
-			j.logger.Infof("Job dequeue successful.")
-		}
+// reportJob dequeues an executed job in the backend and marks it as reported in the journal.
+func (j *JobRunner) reportJob(job types.GenericJob, result types.JobResult) {
+	err := j.haargosClient.CompleteJob(job, result)
+
+	if err != nil {
+		j.logger.Errorf("Job dequeue failed [type=%s, context=%v, err=%s]", job.Type, job.Context, err)
+		return
 	}
+
+	j.logger.Infof("Job dequeue successful.")
+	j.setJobState(job, JobStateReported)
 }
 
 func UnmarshalContext(context interface{}, target interface{}) error {
@@ -200,12 +511,12 @@ func UnmarshalContext(context interface{}, target interface{}) error {
 	return nil
 }
 
-func (j *JobRunner) updateCore(job types.GenericJob, client *client.HaargosClient, supervisorClient *client.HaargosClient, supervisorToken string) {
+func (j *JobRunner) updateCore(job types.GenericJob, client *client.HaargosClient, supervisorClient *client.HaargosClient, supervisorToken string) (*http.Response, error) {
 	j.logger.Infof("Updating core")
 	res, err := supervisorClient.UpdateCore(map[string]string{"Authorization": fmt.Sprintf("Bearer %s", supervisorToken)})
 	j.logger.Infof("Updating core scheduled")
 
-	j.finalizeUpdate(res, err, "", job, client)
+	return res, err
 }
 
 func (j *JobRunner) tryLock() bool {
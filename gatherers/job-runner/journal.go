@@ -0,0 +1,171 @@
+package jobrunner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/evilmint/haargos-agent-golang/types"
+)
+
+type JobState string
+
+const (
+	JobStateReceived  JobState = "received"
+	JobStateExecuting JobState = "executing"
+	JobStateExecuted  JobState = "executed"
+	JobStateReported  JobState = "reported"
+)
+
+// Entries older than this are removed from the journal when pruning.
+const journalRetention = 7 * 24 * time.Hour
+
+type JournalEntry struct {
+	ID        string           `json:"id"`
+	Type      string           `json:"type"`
+	State     JobState         `json:"state"`
+	Result    *types.JobResult `json:"result,omitempty"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+// JobJournal keeps track of the jobs the agent has seen so that a job which
+// already ran is never executed again, even across agent restarts.
+type JobJournal struct {
+	path    string
+	entries map[string]JournalEntry
+	mutex   sync.Mutex
+}
+
+// NewJobJournal loads the journal stored at path. An empty path keeps the
+// journal in memory only.
+func NewJobJournal(path string) (*JobJournal, error) {
+	journal := &JobJournal{
+		path:    path,
+		entries: make(map[string]JournalEntry),
+	}
+
+	if path == "" {
+		return journal, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return journal, nil
+	}
+	if err != nil {
+		return journal, fmt.Errorf("Error reading job journal %s: %w", path, err)
+	}
+
+	var entries []JournalEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return journal, fmt.Errorf("Error decoding job journal %s: %w", path, err)
+	}
+
+	for _, entry := range entries {
+		journal.entries[entry.ID] = entry
+	}
+
+	return journal, nil
+}
+
+func (j *JobJournal) Get(jobID string) (JournalEntry, bool) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	entry, found := j.entries[jobID]
+	return entry, found
+}
+
+// SetState moves the job to state, keeping the result stored for it.
+func (j *JobJournal) SetState(job types.GenericJob, state JobState) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	j.entries[job.ID] = JournalEntry{
+		ID:        job.ID,
+		Type:      job.Type,
+		State:     state,
+		Result:    j.entries[job.ID].Result,
+		UpdatedAt: time.Now(),
+	}
+
+	return j.save()
+}
+
+// SetResult moves the job to state and stores its result, so a failed report can be resent as it was.
+func (j *JobJournal) SetResult(job types.GenericJob, state JobState, result types.JobResult) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	j.entries[job.ID] = JournalEntry{
+		ID:        job.ID,
+		Type:      job.Type,
+		State:     state,
+		Result:    &result,
+		UpdatedAt: time.Now(),
+	}
+
+	return j.save()
+}
+
+// Prune removes reported entries which have not been updated within the retention period.
+// Received entries never ran and are removed as well, entries of jobs which ran but were
+// not reported are kept so the jobs are never executed again.
+func (j *JobJournal) Prune() error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	threshold := time.Now().Add(-journalRetention)
+	pruned := false
+
+	for id, entry := range j.entries {
+		if entry.State != JobStateReported && entry.State != JobStateReceived {
+			continue
+		}
+
+		if entry.UpdatedAt.Before(threshold) {
+			delete(j.entries, id)
+			pruned = true
+		}
+	}
+
+	if !pruned {
+		return nil
+	}
+
+	return j.save()
+}
+
+func (j *JobJournal) save() error {
+	if j.path == "" {
+		return nil
+	}
+
+	entries := make([]JournalEntry, 0, len(j.entries))
+	for _, entry := range j.entries {
+		entries = append(entries, entry)
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("Error encoding job journal: %w", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated journal behind.
+	tmpPath := j.path + ".tmp"
+	if err := os.MkdirAll(filepath.Dir(j.path), 0755); err != nil {
+		return fmt.Errorf("Error creating job journal directory: %w", err)
+	}
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("Error writing job journal %s: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, j.path); err != nil {
+		return fmt.Errorf("Error replacing job journal %s: %w", j.path, err)
+	}
+
+	return nil
+}
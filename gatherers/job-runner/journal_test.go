@@ -0,0 +1,58 @@
+package jobrunner
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/evilmint/haargos-agent-golang/types"
+)
+
+func TestJobJournal_persistsAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "job-journal.json")
+
+	journal, err := NewJobJournal(path)
+	if err != nil {
+		t.Fatalf("NewJobJournal() error = %v", err)
+	}
+
+	job := types.GenericJob{ID: "job-1", Type: "core_update"}
+	result := types.JobResult{Status: types.JobStatusFailed, Reason: "update refused"}
+	if err := journal.SetResult(job, JobStateExecuted, result); err != nil {
+		t.Fatalf("SetResult() error = %v", err)
+	}
+
+	reloaded, err := NewJobJournal(path)
+	if err != nil {
+		t.Fatalf("NewJobJournal() error = %v", err)
+	}
+
+	entry, found := reloaded.Get(job.ID)
+	if !found || entry.State != JobStateExecuted {
+		t.Errorf("Get() = %v, %v, want state %s", entry, found, JobStateExecuted)
+	}
+	if entry.Result == nil || *entry.Result != result {
+		t.Errorf("Get() result = %v, want %v", entry.Result, result)
+	}
+}
+
+func TestJobJournal_Prune(t *testing.T) {
+	journal, _ := NewJobJournal("")
+	journal.entries["old"] = JournalEntry{ID: "old", State: JobStateReported, UpdatedAt: time.Now().Add(-journalRetention - time.Hour)}
+	journal.entries["new"] = JournalEntry{ID: "new", State: JobStateReported, UpdatedAt: time.Now()}
+	journal.entries["unreported"] = JournalEntry{ID: "unreported", State: JobStateExecuted, UpdatedAt: time.Now().Add(-journalRetention - time.Hour)}
+
+	if err := journal.Prune(); err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+
+	if _, found := journal.Get("old"); found {
+		t.Errorf("Prune() kept an entry older than the retention period")
+	}
+	if _, found := journal.Get("new"); !found {
+		t.Errorf("Prune() removed a recent entry")
+	}
+	if _, found := journal.Get("unreported"); !found {
+		t.Errorf("Prune() removed an entry which was not reported")
+	}
+}
@@ -0,0 +1,78 @@
+package jobrunner
+
+import (
+	"fmt"
+
+	"github.com/evilmint/haargos-agent-golang/types"
+)
+
+// Jobs which cannot be undone remotely. They are rejected unless explicitly enabled.
+var destructiveJobTypes = map[string]bool{
+	"host_shutdown":   true,
+	"host_reboot":     true,
+	"addon_uninstall": true,
+}
+
+// JobPolicy decides locally which jobs the agent is willing to execute.
+type JobPolicy struct {
+	// AllowedJobTypes lists the job types which may run. Empty allows every supported type.
+	AllowedJobTypes []string
+	// AllowedAddonSlugs lists the add-ons jobs may act on. Empty allows every add-on.
+	AllowedAddonSlugs []string
+	// AllowDestructive enables job types such as host_shutdown or addon_uninstall.
+	AllowDestructive bool
+	// AllowedServices lists the Home Assistant services call_service jobs may call,
+	// either as domain.service or domain.* for every service of a domain. Empty allows none.
+	AllowedServices []string
+}
+
+// Check returns an error describing why the job is rejected, or nil if it is allowed.
+func (p JobPolicy) Check(job types.GenericJob) error {
+	if destructiveJobTypes[job.Type] && !p.AllowDestructive {
+		return fmt.Errorf("destructive job type %s is disabled on this agent", job.Type)
+	}
+
+	if len(p.AllowedJobTypes) > 0 && !contains(p.AllowedJobTypes, job.Type) {
+		return fmt.Errorf("job type %s is not in the list of allowed job types", job.Type)
+	}
+
+	if len(p.AllowedAddonSlugs) > 0 {
+		var addonContext AddonContext
+		if err := UnmarshalContext(job.Context, &addonContext); err == nil && addonContext.Slug != "" {
+			if !contains(p.AllowedAddonSlugs, addonContext.Slug) {
+				return fmt.Errorf("add-on %s is not in the list of allowed add-ons", addonContext.Slug)
+			}
+		}
+	}
+
+	if job.Type == "call_service" {
+		var serviceContext ServiceCallContext
+		if err := UnmarshalContext(job.Context, &serviceContext); err != nil {
+			return fmt.Errorf("invalid service call context: %w", err)
+		}
+
+		if !p.serviceAllowed(serviceContext.Domain, serviceContext.Service) {
+			return fmt.Errorf("service %s.%s is not in the list of allowed services", serviceContext.Domain, serviceContext.Service)
+		}
+	}
+
+	return nil
+}
+
+func (p JobPolicy) serviceAllowed(domain string, service string) bool {
+	for _, allowed := range p.AllowedServices {
+		if allowed == domain+"."+service || allowed == domain+".*" {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
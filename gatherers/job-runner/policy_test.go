@@ -0,0 +1,74 @@
+package jobrunner
+
+import (
+	"testing"
+
+	"github.com/evilmint/haargos-agent-golang/types"
+)
+
+func TestJobPolicy_Check(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  JobPolicy
+		job     types.GenericJob
+		wantErr bool
+	}{
+		{
+			name:   "default policy allows non-destructive jobs",
+			policy: JobPolicy{},
+			job:    types.GenericJob{Type: "core_restart"},
+		},
+		{
+			name:    "default policy rejects destructive jobs",
+			policy:  JobPolicy{},
+			job:     types.GenericJob{Type: "host_reboot"},
+			wantErr: true,
+		},
+		{
+			name:   "destructive jobs can be enabled",
+			policy: JobPolicy{AllowDestructive: true},
+			job:    types.GenericJob{Type: "host_reboot"},
+		},
+		{
+			name:    "job type outside of allow list is rejected",
+			policy:  JobPolicy{AllowedJobTypes: []string{"addon_restart"}},
+			job:     types.GenericJob{Type: "core_update"},
+			wantErr: true,
+		},
+		{
+			name:    "add-on outside of allow list is rejected",
+			policy:  JobPolicy{AllowedAddonSlugs: []string{"core_mosquitto"}},
+			job:     types.GenericJob{Type: "addon_restart", Context: map[string]interface{}{"addon_id": "core_samba"}},
+			wantErr: true,
+		},
+		{
+			name:   "add-on in allow list is accepted",
+			policy: JobPolicy{AllowedAddonSlugs: []string{"core_mosquitto"}},
+			job:    types.GenericJob{Type: "addon_restart", Context: map[string]interface{}{"addon_id": "core_mosquitto"}},
+		},
+		{
+			name:    "service calls are rejected without an allow list",
+			policy:  JobPolicy{},
+			job:     types.GenericJob{Type: "call_service", Context: map[string]interface{}{"domain": "automation", "service": "reload"}},
+			wantErr: true,
+		},
+		{
+			name:   "service in allowed domain is accepted",
+			policy: JobPolicy{AllowedServices: []string{"automation.*"}},
+			job:    types.GenericJob{Type: "call_service", Context: map[string]interface{}{"domain": "automation", "service": "reload"}},
+		},
+		{
+			name:    "service outside of allow list is rejected",
+			policy:  JobPolicy{AllowedServices: []string{"automation.reload"}},
+			job:     types.GenericJob{Type: "call_service", Context: map[string]interface{}{"domain": "recorder", "service": "purge"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.Check(tt.job); (err != nil) != tt.wantErr {
+				t.Errorf("JobPolicy.Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package jobrunner
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/evilmint/haargos-agent-golang/client"
+)
+
+// The response returned for every simulated call. It satisfies the handlers which
+// inspect the supervisor response, e.g. the add-on options validation.
+const simulatedResponseBody = `{"result":"ok","data":{"valid":true}}`
+
+type RecordedCall struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Body   interface{} `json:"body,omitempty"`
+}
+
+// callRecorder records the calls a job makes. A simulating recorder answers the calls
+// itself instead of performing them.
+type callRecorder struct {
+	simulate bool
+	mutex    sync.Mutex
+	calls    []RecordedCall
+}
+
+func (r *callRecorder) record(call RecordedCall) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.calls = append(r.calls, call)
+}
+
+func (r *callRecorder) Calls() []RecordedCall {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]RecordedCall{}, r.calls...)
+}
+
+// transport returns an http.RoundTripper recording requests before passing them on to next.
+func (r *callRecorder) transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &recordingTransport{recorder: r, next: next}
+}
+
+type recordingTransport struct {
+	recorder *callRecorder
+	next     http.RoundTripper
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	call := RecordedCall{Method: req.Method, URL: req.URL.String()}
+
+	if req.Body != nil {
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		call.Body = decodeRequestBody(data, req.Header.Get("Content-Encoding") == "gzip")
+
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(data))
+	}
+
+	t.recorder.record(call)
+
+	if !t.recorder.simulate {
+		return t.next.RoundTrip(req)
+	}
+
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(simulatedResponseBody)),
+		Request:    req,
+	}, nil
+}
+
+func decodeRequestBody(data []byte, gzipped bool) interface{} {
+	var body io.Reader = bytes.NewReader(data)
+	if gzipped {
+		gzipReader, err := gzip.NewReader(body)
+		if err != nil {
+			return nil
+		}
+		body = gzipReader
+	}
+
+	var payload interface{}
+	if err := json.NewDecoder(body).Decode(&payload); err != nil {
+		return nil
+	}
+	return payload
+}
+
+// recordingRunner returns a runner whose clients record the calls they make.
+func (j *JobRunner) recordingRunner(recorder *callRecorder) *JobRunner {
+	runner := &JobRunner{
+		haargosClient:             j.haargosClient,
+		supervisorClient:          recordingClient(j.supervisorClient, recorder),
+		logger:                    j.logger,
+		statistics:                j.statistics,
+		agentType:                 j.agentType,
+		haConfigPath:              j.haConfigPath,
+		docker:                    j.docker.withTransport(recorder.transport(j.docker.httpClient.Transport)),
+		homeAssistantToken:        j.homeAssistantToken,
+		homeAssistantWebsocketURL: j.homeAssistantWebsocketURL,
+		recorder:                  recorder,
+	}
+
+	if j.homeAssistantClient != nil {
+		runner.homeAssistantClient = recordingClient(j.homeAssistantClient, recorder)
+	}
+
+	return runner
+}
+
+func recordingClient(haargosClient *client.HaargosClient, recorder *callRecorder) *client.HaargosClient {
+	var next http.RoundTripper
+	if haargosClient.HTTPClient != nil {
+		next = haargosClient.HTTPClient.Transport
+	}
+	return haargosClient.WithTransport(recorder.transport(next))
+}
@@ -0,0 +1,82 @@
+package jobrunner
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/evilmint/haargos-agent-golang/types"
+)
+
+// Jobs which affect the whole installation, e.g. by rebooting the host or restarting
+// the supervisor every other job talks to. They never run alongside other jobs.
+var exclusiveJobTypes = map[string]bool{
+	"host_reboot":        true,
+	"host_shutdown":      true,
+	"update_os":          true,
+	"supervisor_update":  true,
+	"supervisor_restart": true,
+}
+
+// jobResource returns the resource a job acts on. Jobs on the same resource run one after another.
+func jobResource(job types.GenericJob) (resource string, exclusive bool) {
+	if exclusiveJobTypes[job.Type] {
+		return "", true
+	}
+
+	var addonContext AddonContext
+	if err := UnmarshalContext(job.Context, &addonContext); err == nil && addonContext.Slug != "" {
+		return "addon:" + addonContext.Slug, false
+	}
+
+	switch {
+	case job.Type == "update_core" || strings.HasPrefix(job.Type, "core_"):
+		return "core", false
+	case strings.HasPrefix(job.Type, "supervisor_"):
+		return "supervisor", false
+	case strings.HasPrefix(job.Type, "store_"):
+		return "store", false
+	}
+
+	return job.Type, false
+}
+
+// resourceQueue orders jobs per resource in the order they were dispatched.
+// Every job gets a channel which is closed once it finished; later jobs wait on it.
+type resourceQueue struct {
+	mutex sync.Mutex
+	tails map[string]chan struct{}
+	// barrier is closed once the last exclusive job finished.
+	barrier chan struct{}
+}
+
+func newResourceQueue() *resourceQueue {
+	return &resourceQueue{tails: make(map[string]chan struct{})}
+}
+
+// enqueue returns the channels to wait on before running and the channel to close afterwards.
+func (q *resourceQueue) enqueue(resource string, exclusive bool) ([]chan struct{}, chan struct{}) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	done := make(chan struct{})
+	var wait []chan struct{}
+
+	if q.barrier != nil {
+		wait = append(wait, q.barrier)
+	}
+
+	if exclusive {
+		for _, tail := range q.tails {
+			wait = append(wait, tail)
+		}
+		q.tails = make(map[string]chan struct{})
+		q.barrier = done
+	} else {
+		if tail, found := q.tails[resource]; found {
+			wait = append(wait, tail)
+		}
+		q.tails[resource] = done
+	}
+
+	return wait, done
+}
@@ -0,0 +1,88 @@
+package jobrunner
+
+import (
+	"testing"
+	"time"
+)
+
+// released returns a channel which is closed once every channel in wait is closed.
+func released(wait []chan struct{}) chan struct{} {
+	ready := make(chan struct{})
+	go func() {
+		for _, previous := range wait {
+			<-previous
+		}
+		close(ready)
+	}()
+
+	return ready
+}
+
+func isReleased(ready chan struct{}, timeout time.Duration) bool {
+	select {
+	case <-ready:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func TestResourceQueue_serializesJobsOnTheSameResource(t *testing.T) {
+	queue := newResourceQueue()
+
+	_, firstDone := queue.enqueue("addon:core_mosquitto", false)
+	secondWait, _ := queue.enqueue("addon:core_mosquitto", false)
+	otherWait, _ := queue.enqueue("addon:core_samba", false)
+
+	second := released(secondWait)
+	if isReleased(second, 50*time.Millisecond) {
+		t.Fatalf("second job on the resource started before the first finished")
+	}
+	if !isReleased(released(otherWait), time.Second) {
+		t.Errorf("job on another resource waited for the first job")
+	}
+
+	close(firstDone)
+	if !isReleased(second, time.Second) {
+		t.Errorf("second job on the resource did not start after the first finished")
+	}
+}
+
+func TestResourceQueue_exclusiveJobWaitsForEveryResource(t *testing.T) {
+	queue := newResourceQueue()
+
+	_, addonDone := queue.enqueue("addon:core_mosquitto", false)
+	_, coreDone := queue.enqueue("core", false)
+	exclusiveWait, _ := queue.enqueue("", true)
+
+	exclusive := released(exclusiveWait)
+
+	close(addonDone)
+	if isReleased(exclusive, 50*time.Millisecond) {
+		t.Fatalf("exclusive job started while a job was still running")
+	}
+
+	close(coreDone)
+	if !isReleased(exclusive, time.Second) {
+		t.Errorf("exclusive job did not start after every job finished")
+	}
+}
+
+func TestResourceQueue_laterJobsWaitForTheBarrier(t *testing.T) {
+	queue := newResourceQueue()
+
+	_, exclusiveDone := queue.enqueue("", true)
+	addonWait, _ := queue.enqueue("addon:core_mosquitto", false)
+	coreWait, _ := queue.enqueue("core", false)
+
+	addon := released(addonWait)
+	core := released(coreWait)
+	if isReleased(addon, 50*time.Millisecond) || isReleased(core, 50*time.Millisecond) {
+		t.Fatalf("job started while an exclusive job was running")
+	}
+
+	close(exclusiveDone)
+	if !isReleased(addon, time.Second) || !isReleased(core, time.Second) {
+		t.Errorf("jobs did not start after the exclusive job finished")
+	}
+}
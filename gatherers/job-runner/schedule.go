@@ -0,0 +1,117 @@
+package jobrunner
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/evilmint/haargos-agent-golang/types"
+)
+
+type ScheduleDecision int
+
+const (
+	ScheduleRun ScheduleDecision = iota
+	ScheduleDefer
+	ScheduleExpire
+)
+
+// MaintenanceWindow is a daily time range in which the listed job types may run.
+// The range may wrap around midnight, e.g. 22:00-02:00.
+type MaintenanceWindow struct {
+	Start    time.Duration
+	End      time.Duration
+	JobTypes []string
+}
+
+// ParseMaintenanceWindow parses a window in the HH:MM-HH:MM format.
+func ParseMaintenanceWindow(value string, jobTypes []string) (*MaintenanceWindow, error) {
+	parts := strings.Split(value, "-")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("maintenance window %s must be in the HH:MM-HH:MM format", value)
+	}
+
+	start, err := parseTimeOfDay(parts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	end, err := parseTimeOfDay(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if start == end {
+		return nil, fmt.Errorf("maintenance window %s is empty, its start must differ from its end", value)
+	}
+
+	return &MaintenanceWindow{Start: start, End: end, JobTypes: jobTypes}, nil
+}
+
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %s: %w", value, err)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (w *MaintenanceWindow) appliesTo(jobType string) bool {
+	return len(w.JobTypes) == 0 || contains(w.JobTypes, jobType)
+}
+
+func (w *MaintenanceWindow) contains(t time.Time) bool {
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+
+	if w.Start <= w.End {
+		return offset >= w.Start && offset < w.End
+	}
+
+	return offset >= w.Start || offset < w.End
+}
+
+// Schedule decides when a job may run based on its not_before/not_after times
+// and the locally configured maintenance window.
+type Schedule struct {
+	Window *MaintenanceWindow
+	// Location is the installation's timezone the window is evaluated in.
+	Location *time.Location
+}
+
+// Check returns whether the job should run now, be deferred to a later run or be expired,
+// along with a human readable reason for the latter two.
+func (s Schedule) Check(job types.GenericJob, now time.Time) (ScheduleDecision, string) {
+	if job.NotAfter != "" {
+		notAfter, err := time.Parse(time.RFC3339, job.NotAfter)
+		if err != nil {
+			return ScheduleExpire, fmt.Sprintf("invalid not_after %s", job.NotAfter)
+		}
+		if now.After(notAfter) {
+			return ScheduleExpire, fmt.Sprintf("job deadline %s has passed", job.NotAfter)
+		}
+	}
+
+	if job.NotBefore != "" {
+		notBefore, err := time.Parse(time.RFC3339, job.NotBefore)
+		if err != nil {
+			return ScheduleExpire, fmt.Sprintf("invalid not_before %s", job.NotBefore)
+		}
+		if now.Before(notBefore) {
+			return ScheduleDefer, fmt.Sprintf("job is not due before %s", job.NotBefore)
+		}
+	}
+
+	if s.Window != nil && s.Window.appliesTo(job.Type) {
+		location := s.Location
+		if location == nil {
+			location = time.Local
+		}
+
+		if !s.Window.contains(now.In(location)) {
+			return ScheduleDefer, "outside of the maintenance window"
+		}
+	}
+
+	return ScheduleRun, ""
+}
@@ -0,0 +1,143 @@
+package jobrunner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/evilmint/haargos-agent-golang/types"
+)
+
+func TestSchedule_Check(t *testing.T) {
+	window, err := ParseMaintenanceWindow("22:00-02:00", []string{"core_update"})
+	if err != nil {
+		t.Fatalf("ParseMaintenanceWindow() error = %v", err)
+	}
+
+	at := func(value string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatalf("time.Parse() error = %v", err)
+		}
+		return parsed
+	}
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+		job      types.GenericJob
+		now      time.Time
+		want     ScheduleDecision
+	}{
+		{
+			name:     "window start runs",
+			schedule: Schedule{Window: window, Location: time.UTC},
+			job:      types.GenericJob{Type: "core_update"},
+			now:      at("2024-01-01T22:00:00Z"),
+			want:     ScheduleRun,
+		},
+		{
+			name:     "window wraps past midnight",
+			schedule: Schedule{Window: window, Location: time.UTC},
+			job:      types.GenericJob{Type: "core_update"},
+			now:      at("2024-01-02T01:59:59Z"),
+			want:     ScheduleRun,
+		},
+		{
+			name:     "window end defers",
+			schedule: Schedule{Window: window, Location: time.UTC},
+			job:      types.GenericJob{Type: "core_update"},
+			now:      at("2024-01-02T02:00:00Z"),
+			want:     ScheduleDefer,
+		},
+		{
+			name:     "outside of the window defers",
+			schedule: Schedule{Window: window, Location: time.UTC},
+			job:      types.GenericJob{Type: "core_update"},
+			now:      at("2024-01-01T12:00:00Z"),
+			want:     ScheduleDefer,
+		},
+		{
+			name:     "window is evaluated in the installation's timezone",
+			schedule: Schedule{Window: window, Location: time.FixedZone("UTC+2", 2*60*60)},
+			job:      types.GenericJob{Type: "core_update"},
+			now:      at("2024-01-01T20:30:00Z"),
+			want:     ScheduleRun,
+		},
+		{
+			name:     "job type not covered by the window runs",
+			schedule: Schedule{Window: window, Location: time.UTC},
+			job:      types.GenericJob{Type: "addon_restart"},
+			now:      at("2024-01-01T12:00:00Z"),
+			want:     ScheduleRun,
+		},
+		{
+			name:     "not_before in the future defers",
+			schedule: Schedule{},
+			job:      types.GenericJob{Type: "addon_restart", NotBefore: "2024-01-01T12:00:01Z"},
+			now:      at("2024-01-01T12:00:00Z"),
+			want:     ScheduleDefer,
+		},
+		{
+			name:     "not_before reached runs",
+			schedule: Schedule{},
+			job:      types.GenericJob{Type: "addon_restart", NotBefore: "2024-01-01T12:00:00Z"},
+			now:      at("2024-01-01T12:00:00Z"),
+			want:     ScheduleRun,
+		},
+		{
+			name:     "not_after reached runs",
+			schedule: Schedule{},
+			job:      types.GenericJob{Type: "addon_restart", NotAfter: "2024-01-01T12:00:00Z"},
+			now:      at("2024-01-01T12:00:00Z"),
+			want:     ScheduleRun,
+		},
+		{
+			name:     "not_after passed expires",
+			schedule: Schedule{},
+			job:      types.GenericJob{Type: "addon_restart", NotAfter: "2024-01-01T12:00:00Z"},
+			now:      at("2024-01-01T12:00:01Z"),
+			want:     ScheduleExpire,
+		},
+		{
+			name:     "not_after passed outside of the window expires",
+			schedule: Schedule{Window: window, Location: time.UTC},
+			job:      types.GenericJob{Type: "core_update", NotAfter: "2024-01-01T12:00:00Z"},
+			now:      at("2024-01-01T13:00:00Z"),
+			want:     ScheduleExpire,
+		},
+		{
+			name:     "invalid not_before expires",
+			schedule: Schedule{},
+			job:      types.GenericJob{Type: "addon_restart", NotBefore: "tomorrow"},
+			now:      at("2024-01-01T12:00:00Z"),
+			want:     ScheduleExpire,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, reason := tt.schedule.Check(tt.job, tt.now); got != tt.want {
+				t.Errorf("Schedule.Check() = %v (%s), want %v", got, reason, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMaintenanceWindow(t *testing.T) {
+	tests := []struct {
+		value   string
+		wantErr bool
+	}{
+		{value: "22:00-02:00"},
+		{value: "02:00-04:30"},
+		{value: "02:00-02:00", wantErr: true},
+		{value: "02:00", wantErr: true},
+		{value: "25:00-02:00", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if _, err := ParseMaintenanceWindow(tt.value, nil); (err != nil) != tt.wantErr {
+				t.Errorf("ParseMaintenanceWindow(%s) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package jobrunner
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/evilmint/haargos-agent-golang/types"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	streamPingInterval   = 30 * time.Second
+	streamReadTimeout    = 2 * streamPingInterval
+	streamMinReconnect   = time.Second
+	streamMaxReconnect   = 5 * time.Minute
+	streamMessageTypeJob = "job"
+)
+
+type streamMessage struct {
+	Type string           `json:"type"`
+	Body types.GenericJob `json:"body"`
+}
+
+// JobStream keeps a websocket open to the backend over which new jobs are pushed
+// as soon as they are created. It reconnects with an increasing delay when the
+// connection drops; polling should be used while it is not connected.
+type JobStream struct {
+	url        string
+	agentToken string
+	logger     *logrus.Logger
+	connected  bool
+	mutex      sync.Mutex
+	// onJob is called for every pushed job.
+	onJob func(job types.GenericJob)
+	// onConnect is called after every (re)connect, so jobs created while disconnected can be fetched.
+	onConnect func()
+}
+
+func NewJobStream(logger *logrus.Logger, url string, agentToken string, onJob func(job types.GenericJob), onConnect func()) *JobStream {
+	return &JobStream{
+		url:        url,
+		agentToken: agentToken,
+		logger:     logger,
+		onJob:      onJob,
+		onConnect:  onConnect,
+	}
+}
+
+func (s *JobStream) IsConnected() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.connected
+}
+
+func (s *JobStream) setConnected(connected bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.connected = connected
+}
+
+// Run connects to the backend and processes pushed jobs. It never returns.
+func (s *JobStream) Run() {
+	delay := streamMinReconnect
+
+	for {
+		connectedAt := time.Now()
+		err := s.listen()
+		s.setConnected(false)
+
+		// Start over with a short delay if the connection was healthy for a while.
+		if time.Since(connectedAt) > streamMaxReconnect {
+			delay = streamMinReconnect
+		}
+
+		s.logger.Warningf("Job stream disconnected, reconnecting in %s: %v", delay, err)
+		time.Sleep(delay)
+
+		delay *= 2
+		if delay > streamMaxReconnect {
+			delay = streamMaxReconnect
+		}
+	}
+}
+
+func (s *JobStream) listen() error {
+	conn, _, err := websocket.DefaultDialer.Dial(s.url, http.Header{"x-agent-token": []string{s.agentToken}})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(streamReadTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(streamReadTimeout))
+	})
+
+	stopPing := make(chan struct{})
+	defer close(stopPing)
+	go s.ping(conn, stopPing)
+
+	s.setConnected(true)
+	s.logger.Info("Job stream connected.")
+	s.onConnect()
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		conn.SetReadDeadline(time.Now().Add(streamReadTimeout))
+
+		var msg streamMessage
+		decoder := json.NewDecoder(bytes.NewReader(message))
+		// Keep the numbers of the job context as they were signed, see signedJobPayload.
+		decoder.UseNumber()
+		if err := decoder.Decode(&msg); err != nil {
+			s.logger.Errorf("Failed decoding job stream message: %s", err)
+			continue
+		}
+
+		if msg.Type == streamMessageTypeJob {
+			s.logger.Infof("Job pushed [type=%s, id=%s]", msg.Body.Type, msg.Body.ID)
+			s.onJob(msg.Body)
+		}
+	}
+}
+
+func (s *JobStream) ping(conn *websocket.Conn, stop chan struct{}) {
+	ticker := time.NewTicker(streamPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+				return
+			}
+		case <-stop:
+			return
+		}
+	}
+}
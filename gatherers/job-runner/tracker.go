@@ -0,0 +1,197 @@
+package jobrunner
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/evilmint/haargos-agent-golang/types"
+)
+
+const (
+	trackPollInterval = 10 * time.Second
+	trackTimeout      = 60 * time.Minute
+)
+
+// updateTracking describes how to follow an update which the supervisor
+// keeps running after its API call has returned.
+type updateTracking struct {
+	// infoPath is the supervisor endpoint reporting the installed and latest version.
+	infoPath string
+	// supervisorJobName is the name of the job in the supervisor jobs API.
+	supervisorJobName string
+	// reference is the supervisor job reference, e.g. the add-on slug.
+	reference string
+	// finished holds the UUIDs of matching supervisor jobs which were done before the update
+	// was started, e.g. left over from an earlier update. It is nil when they are unknown,
+	// then a done job is only accepted once it was seen running.
+	finished map[string]bool
+	// uuid is the supervisor job of this update, once it was found.
+	uuid string
+}
+
+func trackingFor(job types.GenericJob) (*updateTracking, bool) {
+	switch job.Type {
+	case "update_core", "core_update":
+		return &updateTracking{infoPath: "core/info", supervisorJobName: "home_assistant_core_update"}, true
+	case "update_os":
+		return &updateTracking{infoPath: "os/info", supervisorJobName: "os_manager_update"}, true
+	case "supervisor_update":
+		return &updateTracking{infoPath: "supervisor/info", supervisorJobName: "supervisor_update"}, true
+	case "update_addon", "addon_update":
+		var addonContext AddonContext
+		if err := UnmarshalContext(job.Context, &addonContext); err != nil || addonContext.Slug == "" {
+			return nil, false
+		}
+
+		return &updateTracking{
+			infoPath:          fmt.Sprintf("addons/%s/info", addonContext.Slug),
+			supervisorJobName: "addon_manager_update",
+			reference:         addonContext.Slug,
+		}, true
+	}
+
+	return nil, false
+}
+
+// startTracking returns how to track the job's update, or nil if it is not tracked.
+// It must be called before the update is started, to tell its supervisor job apart
+// from the ones of earlier updates.
+func (j *JobRunner) startTracking(job types.GenericJob, supervisorToken string) *updateTracking {
+	tracking, ok := trackingFor(job)
+	if !ok {
+		return nil
+	}
+
+	headers := map[string]string{"Authorization": fmt.Sprintf("Bearer %s", supervisorToken)}
+	supervisorJobs, err := j.supervisorClient.FetchSupervisorJobs(headers)
+	if err != nil {
+		j.logger.Debugf("Failed fetching supervisor jobs: %s", err)
+		return tracking
+	}
+
+	tracking.finished = make(map[string]bool)
+	for _, supervisorJob := range *supervisorJobs {
+		if supervisorJob.Done && tracking.matches(supervisorJob) {
+			tracking.finished[supervisorJob.UUID] = true
+		}
+	}
+
+	return tracking
+}
+
+// trackUpdate polls the supervisor until the update has finished or failed,
+// streaming progress to the backend in the meantime.
+func (j *JobRunner) trackUpdate(job types.GenericJob, tracking *updateTracking, supervisorToken string) types.JobResult {
+	headers := map[string]string{"Authorization": fmt.Sprintf("Bearer %s", supervisorToken)}
+	deadline := time.Now().Add(trackTimeout)
+
+	j.logger.Infof("Tracking job [type=%s, id=%s]", job.Type, job.ID)
+
+	for time.Now().Before(deadline) {
+		time.Sleep(trackPollInterval)
+
+		// The supervisor may be restarting, e.g. during its own update, so request errors are not fatal.
+		supervisorJobDone := false
+		supervisorJobs, err := j.supervisorClient.FetchSupervisorJobs(headers)
+		if err != nil {
+			j.logger.Debugf("Failed fetching supervisor jobs: %s", err)
+		} else if supervisorJob := findSupervisorJob(*supervisorJobs, tracking); supervisorJob != nil {
+			if len(supervisorJob.Errors) > 0 {
+				return types.JobResult{Status: types.JobStatusFailed, Reason: supervisorJobErrors(supervisorJob)}
+			}
+
+			if !supervisorJob.Done {
+				j.sendProgress(job, supervisorJob)
+				continue
+			}
+
+			supervisorJobDone = true
+		}
+
+		info, err := j.supervisorClient.FetchUpdateInfo(tracking.infoPath, headers)
+		if err != nil {
+			j.logger.Debugf("Failed fetching %s: %s", tracking.infoPath, err)
+			continue
+		}
+
+		if !info.UpdateAvailable {
+			j.logger.Infof("Job finished [type=%s, id=%s, version=%s]", job.Type, job.ID, info.Version)
+			return types.JobResult{Status: types.JobStatusCompleted, Data: info}
+		}
+
+		if supervisorJobDone {
+			// The supervisor finished without errors but the version did not change, waiting longer will not help.
+			return types.JobResult{
+				Status: types.JobStatusFailed,
+				Reason: fmt.Sprintf("update finished but version %s is still installed", info.Version),
+				Data:   info,
+			}
+		}
+	}
+
+	return types.JobResult{
+		Status: types.JobStatusFailed,
+		Reason: fmt.Sprintf("update did not finish within %s", trackTimeout),
+	}
+}
+
+func (j *JobRunner) sendProgress(job types.GenericJob, supervisorJob *types.SupervisorJob) {
+	progress := types.JobProgress{Progress: supervisorJob.Progress}
+	if supervisorJob.Stage != nil {
+		progress.Stage = *supervisorJob.Stage
+	}
+
+	j.logger.Infof("Job progress [type=%s, id=%s, stage=%s, progress=%v]", job.Type, job.ID, progress.Stage, progress.Progress)
+
+	// Jobs issued locally are unknown to the backend.
+	if job.ID == "" {
+		return
+	}
+
+	res, err := j.haargosClient.SendJobProgress(job, progress)
+	if err != nil {
+		j.logger.Errorf("Failed sending job progress [type=%s, id=%s, err=%s]", job.Type, job.ID, err)
+	}
+	if res != nil {
+		res.Body.Close()
+	}
+}
+
+func (t *updateTracking) matches(job types.SupervisorJob) bool {
+	if job.Name != t.supervisorJobName {
+		return false
+	}
+
+	return t.reference == "" || (job.Reference != nil && *job.Reference == t.reference)
+}
+
+// findSupervisorJob returns the supervisor job of the tracked update, skipping the ones of earlier updates.
+func findSupervisorJob(jobs []types.SupervisorJob, tracking *updateTracking) *types.SupervisorJob {
+	for i := range jobs {
+		job := &jobs[i]
+		if !tracking.matches(*job) || tracking.finished[job.UUID] {
+			continue
+		}
+		if tracking.uuid != "" && job.UUID != tracking.uuid {
+			continue
+		}
+		if tracking.uuid == "" && tracking.finished == nil && job.Done {
+			continue
+		}
+
+		tracking.uuid = job.UUID
+		return job
+	}
+
+	return nil
+}
+
+func supervisorJobErrors(job *types.SupervisorJob) string {
+	messages := make([]string, 0, len(job.Errors))
+	for _, jobError := range job.Errors {
+		messages = append(messages, jobError.Message)
+	}
+
+	return strings.Join(messages, "; ")
+}
@@ -0,0 +1,56 @@
+package jobrunner
+
+import (
+	"testing"
+
+	"github.com/evilmint/haargos-agent-golang/types"
+)
+
+func TestFindSupervisorJob(t *testing.T) {
+	jobs := []types.SupervisorJob{
+		{Name: "home_assistant_core_update", UUID: "earlier", Done: true},
+		{Name: "home_assistant_core_update", UUID: "current", Done: false},
+	}
+
+	tests := []struct {
+		name     string
+		tracking *updateTracking
+		jobs     []types.SupervisorJob
+		want     string
+	}{
+		{
+			name:     "job of an earlier update is skipped",
+			tracking: &updateTracking{supervisorJobName: "home_assistant_core_update", finished: map[string]bool{"earlier": true}},
+			jobs:     jobs,
+			want:     "current",
+		},
+		{
+			name:     "done job is skipped when earlier updates are unknown",
+			tracking: &updateTracking{supervisorJobName: "home_assistant_core_update"},
+			jobs:     jobs,
+			want:     "current",
+		},
+		{
+			name:     "update which has not started yet is not found",
+			tracking: &updateTracking{supervisorJobName: "home_assistant_core_update", finished: map[string]bool{"earlier": true}},
+			jobs:     jobs[:1],
+		},
+		{
+			name:     "job seen running is followed once done",
+			tracking: &updateTracking{supervisorJobName: "home_assistant_core_update", uuid: "current"},
+			jobs:     []types.SupervisorJob{jobs[0], {Name: "home_assistant_core_update", UUID: "current", Done: true}},
+			want:     "current",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if job := findSupervisorJob(tt.jobs, tt.tracking); job != nil {
+				got = job.UUID
+			}
+			if got != tt.want {
+				t.Errorf("findSupervisorJob() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
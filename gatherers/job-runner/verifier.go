@@ -0,0 +1,107 @@
+package jobrunner
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/evilmint/haargos-agent-golang/types"
+)
+
+// signedJobPayload is the part of a job covered by its signature. The backend signs
+// the compact JSON encoding of this struct: fields in the order below, object keys
+// inside the context sorted, no HTML escaping of <, > and & and numbers written as
+// they appear in the job. Jobs are decoded with json.Number to keep them intact.
+type signedJobPayload struct {
+	ID             string      `json:"id"`
+	Type           string      `json:"type"`
+	Context        interface{} `json:"context"`
+	InstallationID string      `json:"installation_id"`
+	ExpiresAt      string      `json:"expires_at"`
+	NotBefore      string      `json:"not_before"`
+	NotAfter       string      `json:"not_after"`
+}
+
+// JobVerifier checks the detached Ed25519 signature of jobs against a pinned public key
+// and that they were issued for this installation.
+type JobVerifier struct {
+	publicKey      ed25519.PublicKey
+	installationID string
+}
+
+// NewJobVerifier creates a verifier from a base64 encoded Ed25519 public key.
+// Jobs are only accepted when they are signed for installationID.
+func NewJobVerifier(encodedPublicKey string, installationID string) (*JobVerifier, error) {
+	key, err := base64.StdEncoding.DecodeString(encodedPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding public key: %w", err)
+	}
+
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key must be %d bytes, got %d", ed25519.PublicKeySize, len(key))
+	}
+
+	return &JobVerifier{publicKey: ed25519.PublicKey(key), installationID: installationID}, nil
+}
+
+// Verify returns an error if the job is unsigned, expired, issued for another installation
+// or its signature does not match.
+func (v *JobVerifier) Verify(job types.GenericJob, now time.Time) error {
+	if job.Signature == "" {
+		return errors.New("job is not signed")
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(job.Signature)
+	if err != nil {
+		return fmt.Errorf("error decoding job signature: %w", err)
+	}
+
+	payload, err := signaturePayload(job)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(v.publicKey, payload, signature) {
+		return errors.New("job signature is invalid")
+	}
+
+	if job.InstallationID != v.installationID {
+		return fmt.Errorf("job is signed for installation %s, not %s", job.InstallationID, v.installationID)
+	}
+
+	expiresAt, err := time.Parse(time.RFC3339, job.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("job has an invalid expiry: %w", err)
+	}
+
+	if now.After(expiresAt) {
+		return fmt.Errorf("job signature expired at %s", job.ExpiresAt)
+	}
+
+	return nil
+}
+
+func signaturePayload(job types.GenericJob) ([]byte, error) {
+	var payload bytes.Buffer
+	encoder := json.NewEncoder(&payload)
+	encoder.SetEscapeHTML(false)
+
+	err := encoder.Encode(signedJobPayload{
+		ID:             job.ID,
+		Type:           job.Type,
+		Context:        job.Context,
+		InstallationID: job.InstallationID,
+		ExpiresAt:      job.ExpiresAt,
+		NotBefore:      job.NotBefore,
+		NotAfter:       job.NotAfter,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding job signature payload: %w", err)
+	}
+
+	return bytes.TrimSuffix(payload.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,100 @@
+package jobrunner
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evilmint/haargos-agent-golang/types"
+)
+
+func signJob(t *testing.T, privateKey ed25519.PrivateKey, job types.GenericJob) types.GenericJob {
+	payload, err := signaturePayload(job)
+	if err != nil {
+		t.Fatalf("signaturePayload() error = %v", err)
+	}
+
+	job.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, payload))
+	return job
+}
+
+func TestJobVerifier_Verify(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	verifier, err := NewJobVerifier(base64.StdEncoding.EncodeToString(publicKey), "installation-1")
+	if err != nil {
+		t.Fatalf("NewJobVerifier() error = %v", err)
+	}
+
+	now := time.Now()
+	job := types.GenericJob{
+		ID:             "job-1",
+		Type:           "addon_restart",
+		InstallationID: "installation-1",
+		Context:        map[string]interface{}{"addon_id": "core_mosquitto"},
+		ExpiresAt:      now.Add(time.Hour).Format(time.RFC3339),
+	}
+
+	signed := signJob(t, privateKey, job)
+
+	tampered := signed
+	tampered.Type = "host_reboot"
+
+	postponed := signed
+	postponed.NotBefore = now.Add(time.Hour).Format(time.RFC3339)
+
+	expired := job
+	expired.ExpiresAt = now.Add(-time.Hour).Format(time.RFC3339)
+	expired = signJob(t, privateKey, expired)
+
+	otherInstallation := job
+	otherInstallation.InstallationID = "installation-2"
+	otherInstallation = signJob(t, privateKey, otherInstallation)
+
+	tests := []struct {
+		name    string
+		job     types.GenericJob
+		wantErr bool
+	}{
+		{name: "valid signature", job: signed},
+		{name: "unsigned job", job: job, wantErr: true},
+		{name: "tampered job", job: tampered, wantErr: true},
+		{name: "tampered schedule", job: postponed, wantErr: true},
+		{name: "expired job", job: expired, wantErr: true},
+		{name: "job for another installation", job: otherInstallation, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := verifier.Verify(tt.job, now); (err != nil) != tt.wantErr {
+				t.Errorf("JobVerifier.Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignaturePayload_isCanonical(t *testing.T) {
+	decoder := json.NewDecoder(strings.NewReader(`{"id":"job-1","type":"call_service","installation_id":"installation-1","expires_at":"2024-01-01T00:00:00Z","context":{"value":1.10,"entity_id":"light.a<b&c"}}`))
+	decoder.UseNumber()
+
+	var job types.GenericJob
+	if err := decoder.Decode(&job); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	payload, err := signaturePayload(job)
+	if err != nil {
+		t.Fatalf("signaturePayload() error = %v", err)
+	}
+
+	want := `{"id":"job-1","type":"call_service","context":{"entity_id":"light.a<b&c","value":1.10},"installation_id":"installation-1","expires_at":"2024-01-01T00:00:00Z","not_before":"","not_after":""}`
+	if string(payload) != want {
+		t.Errorf("signaturePayload() = %s, want %s", payload, want)
+	}
+}
@@ -35,6 +35,25 @@ func (l *LogGatherer) GatherCoreLogs(haConfigPath string) string {
 	return logContent
 }
 
+// GatherIntegrationLogs returns the warnings and errors logged by the integration's loggers.
+func (l *LogGatherer) GatherIntegrationLogs(haConfigPath string, domain string) []string {
+	logFile := haConfigPath + "home-assistant.log"
+	lines, err := readLogLines(logFile)
+	if err != nil {
+		l.Logger.Errorf("Error reading log file: %v", err)
+		return []string{}
+	}
+
+	var integrationLines []string
+	for _, line := range lines {
+		if strings.Contains(line, "components."+domain+"]") || strings.Contains(line, "components."+domain+".") {
+			integrationLines = append(integrationLines, line)
+		}
+	}
+
+	return filterLogLines(integrationLines)
+}
+
 func (l *LogGatherer) GatherHassioLogs(client *client.HaargosClient, supervisorToken string, logSource string) (string, error) {
 	logs, err := client.FetchText(fmt.Sprintf("%s/logs", logSource), map[string]string{"Authorization": fmt.Sprintf("Bearer %s", supervisorToken)})
 
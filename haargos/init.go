@@ -23,7 +23,7 @@ import (
 	"github.com/evilmint/haargos-agent-golang/gatherers/zigbeedevicegatherer"
 	"github.com/evilmint/haargos-agent-golang/ingress"
 	"github.com/evilmint/haargos-agent-golang/registry"
-	"github.com/evilmint/haargos-agent-golang/repositories/commandrepository"
+	"github.com/evilmint/haargos-agent-golang/repositories/procfsrepository"
 	"github.com/evilmint/haargos-agent-golang/statistics"
 	"github.com/evilmint/haargos-agent-golang/types"
 	websocketclient "github.com/evilmint/haargos-agent-golang/websocket-client"
@@ -41,7 +41,7 @@ type Haargos struct {
 
 func NewHaargos(logger *logrus.Logger, debugEnabled bool) *Haargos {
 	return &Haargos{
-		environmentGatherer: environmentgatherer.NewEnvironmentGatherer(logger, commandrepository.NewCommandRepository(logger)),
+		environmentGatherer: environmentgatherer.NewEnvironmentGatherer(logger, procfsrepository.NewProcfsRepository(logger, "/")),
 		logger:              logger,
 		statistics:          statistics.NewStatistics(),
 	}
@@ -59,6 +59,22 @@ type RunParams struct {
 	Z2MPath      string
 	ZHAPath      string
 	Stage        string
+	DataPath     string
+	JobPolicy    jobrunner.JobPolicy
+	JobPublicKey string
+	// MaintenanceWindow limits MaintenanceJobs to a daily HH:MM-HH:MM range.
+	MaintenanceWindow string
+	MaintenanceJobs   []string
+	// HAContainer is the Home Assistant container controlled by docker agents.
+	HAContainer string
+	// MaxConcurrentJobs bounds how many independent jobs run in parallel.
+	MaxConcurrentJobs int
+	// DryRunJobs reports the calls jobs would make instead of executing them.
+	DryRunJobs bool
+	// StorageFilter selects the filesystems reported in the environment.
+	StorageFilter environmentgatherer.StorageFilter
+	// SampleInterval is the time between environment samples taken between observations, 0 disables sampling.
+	SampleInterval time.Duration
 }
 
 func (h *Haargos) fetchLogs(haConfigPath string, ch chan string, wg *sync.WaitGroup) {
@@ -73,16 +89,14 @@ func (h *Haargos) fetchLogs(haConfigPath string, ch chan string, wg *sync.WaitGr
 func (h *Haargos) calculateDocker(ch chan types.Docker, wg *sync.WaitGroup) {
 	defer wg.Done()
 	h.logger.Debugf("Analyzing Docker environment.")
-	gatherer := dockergatherer.NewDockerGatherer("/var/run/docker.sock")
+	gatherer := dockergatherer.NewDockerGatherer(dockerSocketPath)
 	dockerInfo := gatherer.GatherDocker()
 	ch <- dockerInfo
 }
 
 func (h *Haargos) calculateEnvironment(ch chan types.Environment, wg *sync.WaitGroup) {
 	defer wg.Done()
-	h.environmentGatherer.PausePeriodicTasks()
 	environment := h.environmentGatherer.CalculateEnvironment()
-	h.environmentGatherer.ResumePeriodicTasks()
 	h.logger.Debugf("Retrieved environment data.")
 	ch <- environment
 }
@@ -183,6 +197,14 @@ func (h *Haargos) calculateScenes(
 	ch <- scenes
 }
 
+const dockerSocketPath = "/var/run/docker.sock"
+
+// The size at which the job audit log is rotated.
+const auditLogMaxSize = 1024 * 1024
+
+// The interval at which pending jobs are polled while the job stream is connected.
+const streamedJobPollInterval = 15 * time.Minute
+
 type AgentType string
 
 // Define constants for AgentType.
@@ -195,26 +217,13 @@ func (h *Haargos) Run(params RunParams) {
 	var interval time.Duration
 
 	h.validateAgentType(params.AgentType)
+	h.environmentGatherer.SetStorageFilter(params.StorageFilter)
 
-	var apiURL string
-
-	if params.Stage == Dev {
-		apiURL = "https://api.dev.haargos.com/"
-	} else {
-		apiURL = "https://api.haargos.com/"
-	}
-
-	supervisorEndpoint := "http://supervisor/"
+	apiURL := apiURLForStage(params.Stage)
 
 	supervisorToken := os.Getenv("SUPERVISOR_TOKEN")
-	haargosClient := client.NewClient(apiURL, params.AgentToken, func(number int) {
-		h.statistics.AddDataSentInKB(number)
-	})
-	supervisorClient := client.NewClient(supervisorEndpoint, params.AgentToken, func(number int) {
-		h.statistics.AddDataSentInKB(number)
-	})
-
-	h.jobRunner = jobrunner.NewJobRunner(h.logger, haargosClient, supervisorClient, h.statistics)
+	haargosClient, supervisorClient := h.createClients(apiURL, params.AgentToken)
+	accessToken, haEndpoint := h.homeAssistantAccess(params.HaConfigPath)
 
 	if supervisorToken != "" {
 		h.logger.Info("Supervisor token is set.")
@@ -229,11 +238,23 @@ func (h *Haargos) Run(params RunParams) {
 		return
 	}
 
+	if params.JobPublicKey != "" && agentConfig.InstallationID == "" {
+		h.logger.Warning("Installation ID is not set in the agent config, signed jobs will be rejected.")
+	}
+
+	auditLog := jobrunner.NewAuditLog(path.Join(params.DataPath, "job-audit.log"), auditLogMaxSize)
+	h.jobRunner = h.createJobRunner(params, haargosClient, supervisorClient, agentConfig.InstallationID, path.Join(params.DataPath, "job-journal.json"), auditLog)
+
 	version := h.getAgentVersion()
 	isSupervised := supervisorToken != "" && params.AgentType == "addon"
 
 	interval = time.Duration(agentConfig.CycleInterval) * time.Second
 
+	if params.SampleInterval > 0 {
+		// Twice the samples of a cycle, so a delayed observation still finds all of its samples.
+		h.environmentGatherer.StartSampler(params.SampleInterval, 2*int(interval/params.SampleInterval)+1)
+	}
+
 	runTicker(interval, func() {
 		h.sendLogs(params.HaConfigPath, haargosClient, supervisorClient, supervisorToken)
 	})
@@ -250,13 +271,31 @@ func (h *Haargos) Run(params RunParams) {
 		})
 	}
 
+	jobStream := jobrunner.NewJobStream(
+		h.logger,
+		strings.Replace(apiURL, "https://", "wss://", 1)+"installations/jobs/stream",
+		params.AgentToken,
+		func(job types.GenericJob) {
+			h.jobRunner.DispatchJob(job, supervisorToken)
+		},
+		func() {
+			h.jobRunner.HandleJobs(params.HaConfigPath, supervisorToken)
+		},
+	)
+	go jobStream.Run()
+
+	var jobsHandledAt time.Time
 	runTicker(3*time.Minute, func() {
+		// Pushed jobs arrive over the stream, while it is connected poll less often. The poll still
+		// picks up deferred jobs, jobs to retry and failed reports, which are never pushed again.
+		if jobStream.IsConnected() && time.Since(jobsHandledAt) < streamedJobPollInterval {
+			return
+		}
+
+		jobsHandledAt = time.Now()
 		h.jobRunner.HandleJobs(params.HaConfigPath, supervisorToken)
 	})
 
-	accessToken := os.Getenv("HA_ACCESS_TOKEN")
-	haEndpoint := os.Getenv("HA_ENDPOINT")
-
 	isAccessTokenSet := accessToken != ""
 	h.statistics.SetHAAccessTokenSet(isAccessTokenSet)
 	h.statistics.SetZ2MSet(params.Z2MPath != "")
@@ -264,22 +303,11 @@ func (h *Haargos) Run(params RunParams) {
 	h.statistics.SetAgentVersion(version)
 
 	if isAccessTokenSet {
-		if haEndpoint == "" {
-			port := 8123
-
-			configuration, err := h.readConfiguration(params.HaConfigPath)
-			if err != nil && configuration.Http.ServerPort != nil {
-				port = *configuration.Http.ServerPort
-			}
-
-			haEndpoint = fmt.Sprintf("homeassistant:%d", port)
-		}
-
 		runTicker(interval, func() {
 			h.sendNotifications(params.HaConfigPath, haargosClient, accessToken, haEndpoint)
 		})
 	}
-	h.ingress = ingress.NewIngress(h.statistics)
+	h.ingress = ingress.NewIngress(h.statistics, auditLog)
 	go h.ingress.Run()
 
 	ticker := time.NewTicker(interval)
@@ -345,6 +373,113 @@ func (h *Haargos) Run(params RunParams) {
 	}
 }
 
+func apiURLForStage(stage string) string {
+	if stage == Dev {
+		return "https://api.dev.haargos.com/"
+	}
+	return "https://api.haargos.com/"
+}
+
+func (h *Haargos) createClients(apiURL string, agentToken string) (*client.HaargosClient, *client.HaargosClient) {
+	haargosClient := client.NewClient(apiURL, agentToken, func(number int) {
+		h.statistics.AddDataSentInKB(number)
+	})
+	supervisorClient := client.NewClient("http://supervisor/", agentToken, func(number int) {
+		h.statistics.AddDataSentInKB(number)
+	})
+
+	return haargosClient, supervisorClient
+}
+
+// homeAssistantAccess returns the access token and endpoint of the Home Assistant API.
+func (h *Haargos) homeAssistantAccess(haConfigPath string) (string, string) {
+	accessToken := os.Getenv("HA_ACCESS_TOKEN")
+	haEndpoint := os.Getenv("HA_ENDPOINT")
+
+	if haEndpoint == "" {
+		haEndpoint = h.homeAssistantEndpoint(haConfigPath)
+	}
+
+	return accessToken, haEndpoint
+}
+
+func (h *Haargos) createJobRunner(params RunParams, haargosClient *client.HaargosClient, supervisorClient *client.HaargosClient, installationID string, journalPath string, auditLog *jobrunner.AuditLog) *jobrunner.JobRunner {
+	accessToken, haEndpoint := h.homeAssistantAccess(params.HaConfigPath)
+
+	var homeAssistantClient *client.HaargosClient
+	if accessToken != "" {
+		homeAssistantClient = client.NewClient(fmt.Sprintf("http://%s/api/", haEndpoint), "", func(number int) {
+			h.statistics.AddDataSentInKB(number)
+		})
+	}
+
+	return jobrunner.NewJobRunner(h.logger, haargosClient, supervisorClient, h.statistics, jobrunner.Config{
+		JournalPath:               journalPath,
+		AuditLog:                  auditLog,
+		Policy:                    params.JobPolicy,
+		PublicKey:                 params.JobPublicKey,
+		InstallationID:            installationID,
+		Schedule:                  h.jobSchedule(params),
+		AgentType:                 params.AgentType,
+		HaConfigPath:              params.HaConfigPath,
+		HomeAssistantClient:       homeAssistantClient,
+		HomeAssistantToken:        accessToken,
+		HomeAssistantWebsocketURL: fmt.Sprintf("ws://%s/api/websocket", haEndpoint),
+		DockerSocketPath:          dockerSocketPath,
+		HomeAssistantContainer:    params.HAContainer,
+		MaxConcurrentJobs:         params.MaxConcurrentJobs,
+		DryRun:                    params.DryRunJobs,
+	})
+}
+
+// localJobRunner creates a job runner for jobs issued on this machine. It keeps no journal or audit log.
+func (h *Haargos) localJobRunner(params RunParams) *jobrunner.JobRunner {
+	h.validateAgentType(params.AgentType)
+
+	haargosClient, supervisorClient := h.createClients(apiURLForStage(params.Stage), params.AgentToken)
+	return h.createJobRunner(params, haargosClient, supervisorClient, "", "", nil)
+}
+
+// JobTypes lists the job types supported by the agent type.
+func (h *Haargos) JobTypes(params RunParams) []jobrunner.JobTypeInfo {
+	return h.localJobRunner(params).JobTypes()
+}
+
+// RunJob executes a job locally through the same handlers as jobs issued by the backend.
+func (h *Haargos) RunJob(params RunParams, job types.GenericJob) (types.JobResult, error) {
+	return h.localJobRunner(params).RunJob(job, os.Getenv("SUPERVISOR_TOKEN"))
+}
+
+func (h *Haargos) jobSchedule(params RunParams) jobrunner.Schedule {
+	if params.MaintenanceWindow == "" {
+		return jobrunner.Schedule{}
+	}
+
+	window, err := jobrunner.ParseMaintenanceWindow(params.MaintenanceWindow, params.MaintenanceJobs)
+	if err != nil {
+		h.logger.Fatalf("Invalid maintenance window: %s", err)
+	}
+
+	return jobrunner.Schedule{Window: window, Location: h.installationLocation(params.HaConfigPath)}
+}
+
+// installationLocation returns the timezone configured in Home Assistant, falling back to the local one.
+func (h *Haargos) installationLocation(haConfigPath string) *time.Location {
+	coreConfig, err := registry.ReadCoreConfig(haConfigPath)
+	if err != nil || coreConfig.Data.TimeZone == "" {
+		h.logger.Warningf("Failed reading Home Assistant timezone, using local time: %v", err)
+		return time.Local
+	}
+
+	location, err := time.LoadLocation(coreConfig.Data.TimeZone)
+	if err != nil {
+		h.logger.Warningf("Unknown Home Assistant timezone %s, using local time: %v", coreConfig.Data.TimeZone, err)
+		return time.Local
+	}
+
+	return location
+}
+
 func (h *Haargos) getAgentVersion() string {
 	data, err := os.ReadFile("VERSION")
 	if err != nil {
@@ -489,6 +624,18 @@ type ConfigurationHttp struct {
 	ServerPort *int `yaml:"server_port"`
 }
 
+// homeAssistantEndpoint returns the host and port Home Assistant listens on.
+func (h *Haargos) homeAssistantEndpoint(haConfigPath string) string {
+	port := 8123
+
+	configuration, err := h.readConfiguration(haConfigPath)
+	if err == nil && configuration.Http != nil && configuration.Http.ServerPort != nil {
+		port = *configuration.Http.ServerPort
+	}
+
+	return fmt.Sprintf("homeassistant:%d", port)
+}
+
 func (h *Haargos) readConfiguration(haConfigPath string) (*Configuration, error) {
 	haConfigData, err := os.ReadFile(haConfigPath + "/configuration.yaml")
 
@@ -6,16 +6,22 @@ import (
 	"net/http"
 	"path/filepath"
 
+	jobrunner "github.com/evilmint/haargos-agent-golang/gatherers/job-runner"
 	"github.com/evilmint/haargos-agent-golang/statistics"
 )
 
+// The number of audit log entries shown on the page.
+const recentJobsCount = 20
+
 type Ingress struct {
-	Stats *statistics.Statistics
+	Stats    *statistics.Statistics
+	AuditLog *jobrunner.AuditLog
 }
 
-func NewIngress(stats *statistics.Statistics) *Ingress {
+func NewIngress(stats *statistics.Statistics, auditLog *jobrunner.AuditLog) *Ingress {
 	return &Ingress{
-		Stats: stats,
+		Stats:    stats,
+		AuditLog: auditLog,
 	}
 }
 
@@ -54,7 +60,13 @@ func (i *Ingress) Run() error {
 			isZ2MSet = "No"
 		}
 
-		renderTemplate(w, "index.html", map[string]string{
+		recentJobs, err := i.AuditLog.Recent(recentJobsCount)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		renderTemplate(w, "index.html", map[string]interface{}{
 			"Title":   "Haargos",
 			"Heading": "Haargos main",
 			"Uptime":  uptime,
@@ -73,6 +85,7 @@ func (i *Ingress) Run() error {
 			"Z2MPathSet":         isZ2MSet,
 			"ZHAPathSet":         isZHASet,
 			"AgentVersion":       i.Stats.GetAgentVersion(),
+			"RecentJobs":         recentJobs,
 		})
 	})
 
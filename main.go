@@ -1,10 +1,17 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
+	"time"
+	_ "time/tzdata"
 
+	"github.com/evilmint/haargos-agent-golang/gatherers/environmentgatherer"
+	jobrunner "github.com/evilmint/haargos-agent-golang/gatherers/job-runner"
 	"github.com/evilmint/haargos-agent-golang/haargos"
+	"github.com/evilmint/haargos-agent-golang/types"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -27,6 +34,7 @@ func main() {
 	rootCmd.AddCommand(createVersionCommand())
 	rootCmd.AddCommand(createHelpCommand())
 	rootCmd.AddCommand(createRunCommand())
+	rootCmd.AddCommand(createJobsCommand())
 
 	if err := rootCmd.Execute(); err != nil {
 		logger.Fatalf("Error executing command: %v", err)
@@ -56,8 +64,15 @@ func createHelpCommand() *cobra.Command {
 }
 
 func createRunCommand() *cobra.Command {
-	var haConfigPath, z2mPath, zhaPath, agentType string
+	var haConfigPath, z2mPath, zhaPath, agentType, dataPath, maintenanceWindow, haContainer string
+	var maintenanceJobs []string
+	var maxConcurrentJobs int
+	var dryRunJobs bool
+	var jobPolicy jobrunner.JobPolicy
+	var storageFilter environmentgatherer.StorageFilter
+	var sampleInterval time.Duration
 	agentToken := os.Getenv("HAARGOS_AGENT_TOKEN")
+	jobPublicKey := os.Getenv("HAARGOS_JOB_PUBLIC_KEY")
 	var stage = os.Getenv("STAGE")
 
 	if stage == "" {
@@ -78,12 +93,22 @@ func createRunCommand() *cobra.Command {
 			haargosClient := haargos.NewHaargos(logger, debugEnabled)
 			haargosClient.Run(
 				haargos.RunParams{
-					AgentToken:   agentToken,
-					AgentType:    agentType,
-					HaConfigPath: haConfigPath,
-					Z2MPath:      z2mPath,
-					ZHAPath:      zhaPath,
-					Stage:        stage,
+					AgentToken:        agentToken,
+					AgentType:         agentType,
+					HaConfigPath:      haConfigPath,
+					Z2MPath:           z2mPath,
+					ZHAPath:           zhaPath,
+					Stage:             stage,
+					DataPath:          dataPath,
+					JobPolicy:         jobPolicy,
+					JobPublicKey:      jobPublicKey,
+					MaintenanceWindow: maintenanceWindow,
+					MaintenanceJobs:   maintenanceJobs,
+					HAContainer:       haContainer,
+					MaxConcurrentJobs: maxConcurrentJobs,
+					DryRunJobs:        dryRunJobs,
+					StorageFilter:     storageFilter,
+					SampleInterval:    sampleInterval,
 				},
 			)
 		},
@@ -93,10 +118,104 @@ func createRunCommand() *cobra.Command {
 	cmdRun.Flags().StringVarP(&z2mPath, "z2m-path", "z", "", "Path to Z2M database")
 	cmdRun.Flags().StringVarP(&zhaPath, "zha-path", "x", "", "Path to ZHA database")
 	cmdRun.Flags().StringVarP(&agentType, "agent-type", "t", "bin", "Agent type")
+	cmdRun.Flags().StringVarP(&dataPath, "data-path", "d", ".", "Path where the agent persists its state")
+	cmdRun.Flags().StringSliceVar(&jobPolicy.AllowedJobTypes, "allowed-jobs", []string{}, "Job types the agent may execute (default all)")
+	cmdRun.Flags().StringSliceVar(&jobPolicy.AllowedAddonSlugs, "allowed-addons", []string{}, "Add-on slugs jobs may act on (default all)")
+	cmdRun.Flags().StringSliceVar(&jobPolicy.AllowedServices, "allowed-services", []string{}, "Home Assistant services call_service jobs may call, as domain.service or domain.*")
+	cmdRun.Flags().StringVar(&jobPublicKey, "job-public-key", jobPublicKey, "Base64 encoded Ed25519 key jobs must be signed with")
+	cmdRun.Flags().BoolVar(&jobPolicy.AllowDestructive, "allow-destructive-jobs", false, "Allow host_reboot, host_shutdown and addon_uninstall jobs")
+	cmdRun.Flags().StringVar(&maintenanceWindow, "maintenance-window", "", "Daily HH:MM-HH:MM window in the installation's timezone in which maintenance jobs run")
+	cmdRun.Flags().StringSliceVar(&maintenanceJobs, "maintenance-jobs", []string{"update_core", "core_update", "update_os", "update_addon", "addon_update", "supervisor_update"}, "Job types restricted to the maintenance window")
+	cmdRun.Flags().StringVar(&haContainer, "ha-container", "homeassistant", "Name of the Home Assistant container restarted by docker agents")
+	cmdRun.Flags().IntVar(&maxConcurrentJobs, "max-concurrent-jobs", 4, "Maximum number of independent jobs executed in parallel")
+	cmdRun.Flags().BoolVar(&dryRunJobs, "dry-run-jobs", false, "Report the calls jobs would make without executing them")
+	cmdRun.Flags().StringSliceVar(&storageFilter.IncludeFSTypes, "storage-include-fstypes", []string{}, "Filesystem types reported as storage (default all)")
+	cmdRun.Flags().StringSliceVar(&storageFilter.ExcludeFSTypes, "storage-exclude-fstypes", []string{"tmpfs", "devtmpfs", "squashfs", "erofs"}, "Filesystem types left out of the storage")
+	cmdRun.Flags().StringSliceVar(&storageFilter.IncludeMountPoints, "storage-include-mounts", []string{}, "Mount points reported as storage, including the mounts beneath them (default all)")
+	cmdRun.Flags().StringSliceVar(&storageFilter.ExcludeMountPoints, "storage-exclude-mounts", []string{}, "Mount points left out of the storage, including the mounts beneath them")
+	cmdRun.Flags().DurationVar(&sampleInterval, "sample-interval", 5*time.Second, "Interval at which the environment is sampled between observations, 0 disables sampling")
 
 	return cmdRun
 }
 
+func createJobsCommand() *cobra.Command {
+	var params haargos.RunParams
+	params.AgentToken = os.Getenv("HAARGOS_AGENT_TOKEN")
+	params.JobPublicKey = os.Getenv("HAARGOS_JOB_PUBLIC_KEY")
+	params.Stage = os.Getenv("STAGE")
+
+	cmdJobs := &cobra.Command{
+		Use:   "jobs",
+		Short: "List and run jobs locally",
+	}
+
+	cmdJobs.PersistentFlags().StringVarP(&params.HaConfigPath, "ha-config", "c", "", "Path to the Home Assistant configuration")
+	// Inside the add-on the supervisor token is set, so jobs run through the supervisor like the add-on's agent does.
+	defaultAgentType := "bin"
+	if os.Getenv("SUPERVISOR_TOKEN") != "" {
+		defaultAgentType = "addon"
+	}
+
+	cmdJobs.PersistentFlags().StringVarP(&params.AgentType, "agent-type", "t", defaultAgentType, "Agent type")
+	cmdJobs.PersistentFlags().StringVar(&params.HAContainer, "ha-container", "homeassistant", "Name of the Home Assistant container restarted by docker agents")
+
+	cmdJobs.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the supported job types and their context",
+		Run: func(cmd *cobra.Command, args []string) {
+			for _, jobType := range haargos.NewHaargos(logger, false).JobTypes(params) {
+				var fields []string
+				for _, field := range jobType.Context {
+					fields = append(fields, fmt.Sprintf("%s (%s)", field.Name, field.Type))
+				}
+				fmt.Printf("%-24s %s\n", jobType.Type, strings.Join(fields, ", "))
+			}
+		},
+	})
+
+	var addonSlug, contextJSON string
+	var dryRun bool
+
+	cmdRun := &cobra.Command{
+		Use:   "run <job-type>",
+		Short: "Run a job locally and print its result",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			context := map[string]interface{}{}
+			if contextJSON != "" {
+				if err := json.Unmarshal([]byte(contextJSON), &context); err != nil {
+					logger.Fatalf("Invalid job context: %v", err)
+				}
+			}
+			if addonSlug != "" {
+				context["addon_id"] = addonSlug
+			}
+			if dryRun {
+				context["dry_run"] = true
+			}
+
+			result, err := haargos.NewHaargos(logger, false).RunJob(params, types.GenericJob{Type: args[0], Context: context})
+			if err != nil {
+				logger.Fatalf("Error running job: %v", err)
+			}
+
+			output, _ := json.MarshalIndent(result, "", "  ")
+			fmt.Println(string(output))
+
+			if result.Status != types.JobStatusCompleted {
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmdRun.Flags().StringVar(&addonSlug, "addon", "", "Slug of the add-on the job acts on")
+	cmdRun.Flags().StringVar(&contextJSON, "context", "", "Job context as a JSON object")
+	cmdRun.Flags().BoolVar(&dryRun, "dry-run", false, "Report the calls the job would make without executing it")
+	cmdJobs.AddCommand(cmdRun)
+
+	return cmdJobs
+}
+
 func logMissingFlags(haConfigPath, agentToken string) {
 	if haConfigPath == "" {
 		logger.Fatal("The --ha-config flag must be provided")
@@ -52,3 +52,45 @@ func ReadEntityRegistry(haConfigPath string) (types.EntityRegistry, error) {
 
 	return response, nil
 }
+
+func ReadCoreConfig(haConfigPath string) (types.CoreConfig, error) {
+	path := haConfigPath + ".storage/core.config"
+	file, err := os.Open(path)
+	if err != nil {
+		return types.CoreConfig{}, fmt.Errorf("Error opening file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var response types.CoreConfig
+	decoder := json.NewDecoder(file)
+	if err := decoder.Decode(&response); err != nil {
+		return types.CoreConfig{}, fmt.Errorf(
+			"Error decoding JSON from file %s: %w",
+			path,
+			err,
+		)
+	}
+
+	return response, nil
+}
+
+func ReadConfigEntries(haConfigPath string) (types.ConfigEntries, error) {
+	path := haConfigPath + ".storage/core.config_entries"
+	file, err := os.Open(path)
+	if err != nil {
+		return types.ConfigEntries{}, fmt.Errorf("Error opening file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var response types.ConfigEntries
+	decoder := json.NewDecoder(file)
+	if err := decoder.Decode(&response); err != nil {
+		return types.ConfigEntries{}, fmt.Errorf(
+			"Error decoding JSON from file %s: %w",
+			path,
+			err,
+		)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,1315 @@
+package procfsrepository
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ProcfsRepository reads system information from procfs, sysfs and statfs. All
+// paths are resolved below root, which allows pointing it at fixture directories.
+type ProcfsRepository struct {
+	Logger *logrus.Logger
+	root   string
+}
+
+func NewProcfsRepository(logger *logrus.Logger, root string) *ProcfsRepository {
+	return &ProcfsRepository{
+		Logger: logger,
+		root:   root,
+	}
+}
+
+type CPUInfo struct {
+	Architecture string
+	Model        string
+	MHz          string
+	CPUCount     int
+}
+
+// CPUTimes holds the time a CPU spent in each state, in USER_HZ units, as listed in /proc/stat.
+type CPUTimes struct {
+	User    uint64
+	Nice    uint64
+	System  uint64
+	Idle    uint64
+	IOWait  uint64
+	IRQ     uint64
+	SoftIRQ uint64
+	Steal   uint64
+}
+
+func (t CPUTimes) Total() uint64 {
+	return t.User + t.Nice + t.System + t.Idle + t.IOWait + t.IRQ + t.SoftIRQ + t.Steal
+}
+
+func (t CPUTimes) Busy() uint64 {
+	return t.Total() - t.Idle - t.IOWait
+}
+
+type CPUStat struct {
+	Total CPUTimes
+	Cores []CPUTimes
+}
+
+// LoadAverage holds the number of runnable processes averaged over 1, 5 and 15 minutes.
+type LoadAverage struct {
+	One     float64
+	Five    float64
+	Fifteen float64
+}
+
+type Mount struct {
+	Device     string
+	MountPoint string
+	FSType     string
+	Options    []string
+}
+
+// FSStat holds the statfs figures of a filesystem, in bytes.
+type FSStat struct {
+	Size      uint64
+	Free      uint64
+	Available uint64
+	Files     uint64
+	FilesFree uint64
+}
+
+func (c *ProcfsRepository) path(elem ...string) string {
+	return filepath.Join(append([]string{c.root}, elem...)...)
+}
+
+func (c *ProcfsRepository) readString(elem ...string) (string, error) {
+	bytes, err := os.ReadFile(c.path(elem...))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(bytes)), nil
+}
+
+func (c *ProcfsRepository) readUint(elem ...string) (uint64, error) {
+	str, err := c.readString(elem...)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(str, 10, 64)
+}
+
+func (c *ProcfsRepository) readArchitecture() (string, error) {
+	arch, err := c.readString("proc", "sys", "kernel", "arch")
+	if err == nil {
+		return arch, nil
+	}
+
+	// The file only exists since Linux 6.1.
+	return machine()
+}
+
+func (c *ProcfsRepository) readCurrentFrequency() (*float32, error) {
+	files := []string{
+		"cpuinfo_cur_freq",
+		"scaling_cur_freq",
+		"cpuinfo_max_freq",
+		"scaling_max_freq",
+	}
+
+	var err error
+	for _, file := range files {
+		var str string
+		str, err = c.readString("sys", "devices", "system", "cpu", "cpufreq", "policy0", file)
+		if err == nil {
+			var freq float64
+			freq, err = strconv.ParseFloat(str, 32)
+			if err == nil {
+				freq32 := float32(freq)
+				return &freq32, nil
+			}
+		}
+	}
+
+	return nil, err
+}
+
+func (c *ProcfsRepository) GetCPUInfo() (*CPUInfo, error) {
+	file, err := os.Open(c.path("proc", "cpuinfo"))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	cpuInfo := CPUInfo{}
+	scanner := bufio.NewScanner(file)
+	cpuCount := 0
+
+	arch, err := c.readArchitecture()
+	if err != nil {
+		return nil, err
+	}
+
+	mHz, err := c.readCurrentFrequency()
+	cpuInfo.Architecture = arch
+
+	if err != nil {
+		c.Logger.Errorf("Failed to read CPU frequency: %s.", err)
+	} else {
+		cpuInfo.MHz = fmt.Sprintf("%.4f", *mHz/1000.0)
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "processor") {
+			cpuCount++
+		}
+		if strings.HasPrefix(line, "model name") || strings.HasPrefix(line, "CPU part") {
+			parts := strings.Split(line, ":")
+			if len(parts) == 2 {
+				cpuInfo.Model = decodeCPUModel(strings.TrimSpace(parts[1]))
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	cpuInfo.CPUCount = cpuCount
+	return &cpuInfo, nil
+}
+
+// GetCPUStat returns the time spent in each state since boot, in total and per core.
+func (c *ProcfsRepository) GetCPUStat() (*CPUStat, error) {
+	file, err := os.Open(c.path("proc", "stat"))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	stat := CPUStat{}
+	foundTotal := false
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+
+		times, err := parseCPUTimes(fields[1:])
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing %s times: %w", fields[0], err)
+		}
+
+		if fields[0] == "cpu" {
+			stat.Total = times
+			foundTotal = true
+		} else {
+			stat.Cores = append(stat.Cores, times)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !foundTotal {
+		return nil, fmt.Errorf("No CPU times found in %s", c.path("proc", "stat"))
+	}
+
+	return &stat, nil
+}
+
+func parseCPUTimes(fields []string) (CPUTimes, error) {
+	values := make([]uint64, 8)
+	for i := 0; i < len(values) && i < len(fields); i++ {
+		value, err := strconv.ParseUint(fields[i], 10, 64)
+		if err != nil {
+			return CPUTimes{}, err
+		}
+		values[i] = value
+	}
+
+	return CPUTimes{
+		User:    values[0],
+		Nice:    values[1],
+		System:  values[2],
+		Idle:    values[3],
+		IOWait:  values[4],
+		IRQ:     values[5],
+		SoftIRQ: values[6],
+		Steal:   values[7],
+	}, nil
+}
+
+func (c *ProcfsRepository) GetLoadAverage() (*LoadAverage, error) {
+	loadavg, err := c.readString("proc", "loadavg")
+	if err != nil {
+		return nil, err
+	}
+
+	fields := strings.Fields(loadavg)
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("Unexpected loadavg format: %q", loadavg)
+	}
+
+	var values [3]float64
+	for i := range values {
+		if values[i], err = strconv.ParseFloat(fields[i], 64); err != nil {
+			return nil, fmt.Errorf("Error parsing loadavg: %w", err)
+		}
+	}
+
+	return &LoadAverage{One: values[0], Five: values[1], Fifteen: values[2]}, nil
+}
+
+// GetMemInfo returns the values of /proc/meminfo by name, in kB.
+func (c *ProcfsRepository) GetMemInfo() (map[string]uint64, error) {
+	file, err := os.Open(c.path("proc", "meminfo"))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	memInfo := make(map[string]uint64)
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		name, value, found := strings.Cut(scanner.Text(), ":")
+		if !found {
+			continue
+		}
+
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+
+		number, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing meminfo %s: %w", name, err)
+		}
+		memInfo[name] = number
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return memInfo, nil
+}
+
+func (c *ProcfsRepository) GetUptime() (time.Duration, error) {
+	uptime, err := c.readString("proc", "uptime")
+	if err != nil {
+		return 0, err
+	}
+
+	fields := strings.Fields(uptime)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("Unexpected uptime format: %q", uptime)
+	}
+
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("Error parsing uptime: %w", err)
+	}
+
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+func (c *ProcfsRepository) GetMounts() ([]Mount, error) {
+	file, err := os.Open(c.path("proc", "mounts"))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var mounts []Mount
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+
+		mounts = append(mounts, Mount{
+			Device:     unescapeMountField(fields[0]),
+			MountPoint: unescapeMountField(fields[1]),
+			FSType:     fields[2],
+			Options:    strings.Split(fields[3], ","),
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return mounts, nil
+}
+
+// unescapeMountField decodes the octal escapes used in /proc/mounts for spaces and similar characters.
+func unescapeMountField(field string) string {
+	if !strings.Contains(field, "\\") {
+		return field
+	}
+
+	var builder strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+3 < len(field) {
+			if value, err := strconv.ParseUint(field[i+1:i+4], 8, 8); err == nil {
+				builder.WriteByte(byte(value))
+				i += 3
+				continue
+			}
+		}
+		builder.WriteByte(field[i])
+	}
+
+	return builder.String()
+}
+
+// Statfs returns the size and usage of the filesystem mounted at mountPoint.
+func (c *ProcfsRepository) Statfs(mountPoint string) (*FSStat, error) {
+	return statfs(c.path(mountPoint))
+}
+
+// Block device sizes and I/O counters are in 512 byte sectors, whatever the device's sector size.
+const sectorSize = 512
+
+// DiskStat holds the I/O counters of a block device, as listed in /proc/diskstats. Times are in milliseconds.
+type DiskStat struct {
+	Name            string
+	ReadsCompleted  uint64
+	SectorsRead     uint64
+	ReadTime        uint64
+	WritesCompleted uint64
+	SectorsWritten  uint64
+	WriteTime       uint64
+	InFlight        uint64
+	IOTime          uint64
+	// WeightedIOTime grows by the number of requests in flight every millisecond.
+	WeightedIOTime uint64
+}
+
+func (d DiskStat) BytesRead() uint64 {
+	return d.SectorsRead * sectorSize
+}
+
+func (d DiskStat) BytesWritten() uint64 {
+	return d.SectorsWritten * sectorSize
+}
+
+// BlockDevice describes a whole disk listed in /sys/block.
+type BlockDevice struct {
+	Name       string
+	Model      string
+	Size       uint64
+	Rotational bool
+	Removable  bool
+}
+
+// GetDiskStats returns the I/O counters of every block device and partition.
+func (c *ProcfsRepository) GetDiskStats() ([]DiskStat, error) {
+	file, err := os.Open(c.path("proc", "diskstats"))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var stats []DiskStat
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 14 {
+			continue
+		}
+
+		var counters [11]uint64
+		for i := range counters {
+			if counters[i], err = strconv.ParseUint(fields[i+3], 10, 64); err != nil {
+				return nil, fmt.Errorf("Error parsing diskstats of %s: %w", fields[2], err)
+			}
+		}
+
+		stats = append(stats, DiskStat{
+			Name:            fields[2],
+			ReadsCompleted:  counters[0],
+			SectorsRead:     counters[2],
+			ReadTime:        counters[3],
+			WritesCompleted: counters[4],
+			SectorsWritten:  counters[6],
+			WriteTime:       counters[7],
+			InFlight:        counters[8],
+			IOTime:          counters[9],
+			WeightedIOTime:  counters[10],
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// GetBlockDevices returns the disks in /sys/block, leaving out loop and RAM disks as well as empty devices.
+func (c *ProcfsRepository) GetBlockDevices() ([]BlockDevice, error) {
+	entries, err := os.ReadDir(c.path("sys", "block"))
+	if err != nil {
+		return nil, err
+	}
+
+	var devices []BlockDevice
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") {
+			continue
+		}
+
+		sectors, err := c.readUint("sys", "block", name, "size")
+		if err != nil || sectors == 0 {
+			continue
+		}
+
+		rotational, _ := c.readString("sys", "block", name, "queue", "rotational")
+		removable, _ := c.readString("sys", "block", name, "removable")
+
+		devices = append(devices, BlockDevice{
+			Name:       name,
+			Model:      c.readBlockDeviceModel(name),
+			Size:       sectors * sectorSize,
+			Rotational: rotational == "1",
+			Removable:  removable == "1",
+		})
+	}
+
+	return devices, nil
+}
+
+// readBlockDeviceModel reads the model of SCSI, SATA and NVMe disks, or the card name of SD cards and eMMC.
+func (c *ProcfsRepository) readBlockDeviceModel(name string) string {
+	for _, file := range []string{"model", "name"} {
+		if model, err := c.readString("sys", "block", name, "device", file); err == nil && model != "" {
+			return model
+		}
+	}
+
+	return ""
+}
+
+// DiskHealth holds the wear and health indicators a disk exposes in sysfs.
+type DiskHealth struct {
+	// Type is MMC or SD for MMC devices and NVMe for NVMe namespaces.
+	Type string
+	// LifeTimeA and LifeTimeB estimate the used life of an eMMC's SLC and MLC areas in 10% steps,
+	// from 1 for up to 10% to 11 once the estimated life is exceeded. 0 when not reported.
+	LifeTimeA uint64
+	LifeTimeB uint64
+	// PreEOL is the eMMC's reserved blocks state, 1 when normal, 2 on a warning and 3 when urgent.
+	PreEOL uint64
+	// State is the NVMe controller state, e.g. live or dead.
+	State string
+	// TemperatureAlarm is raised while an NVMe controller reports a critical temperature warning.
+	TemperatureAlarm bool
+}
+
+// The card types the MMC subsystem reports in device/type for cards with a block device.
+// SDIO cards are left out, they never have one.
+var mmcCardTypes = map[string]bool{"MMC": true, "SD": true, "SD-combo": true}
+
+// GetDiskHealth returns the health indicators of the disk, if it is an MMC or NVMe device.
+func (c *ProcfsRepository) GetDiskHealth(name string) (*DiskHealth, bool) {
+	// SCSI devices, e.g. SATA and USB disks, report their SCSI type there as well, e.g. 0.
+	if mmcType, err := c.readString("sys", "block", name, "device", "type"); err == nil && mmcCardTypes[mmcType] {
+		health := &DiskHealth{Type: mmcType}
+
+		if lifeTime, err := c.readString("sys", "block", name, "device", "life_time"); err == nil {
+			if fields := strings.Fields(lifeTime); len(fields) == 2 {
+				health.LifeTimeA, _ = strconv.ParseUint(fields[0], 0, 8)
+				health.LifeTimeB, _ = strconv.ParseUint(fields[1], 0, 8)
+			}
+		}
+		if preEOL, err := c.readString("sys", "block", name, "device", "pre_eol_info"); err == nil {
+			health.PreEOL, _ = strconv.ParseUint(preEOL, 0, 8)
+		}
+
+		return health, true
+	}
+
+	if state, err := c.readString("sys", "block", name, "device", "state"); err == nil && strings.HasPrefix(name, "nvme") {
+		health := &DiskHealth{Type: "NVMe", State: state}
+
+		// The controller's hwmon device reflects the temperature bit of the SMART critical warning.
+		alarms, _ := filepath.Glob(c.path("sys", "block", name, "device", "hwmon*", "temp1_alarm"))
+		for _, alarm := range alarms {
+			if value, err := os.ReadFile(alarm); err == nil && strings.TrimSpace(string(value)) == "1" {
+				health.TemperatureAlarm = true
+			}
+		}
+
+		return health, true
+	}
+
+	return nil, false
+}
+
+// MountInfo describes a mount listed in /proc/self/mountinfo. Unlike /proc/mounts it tells the
+// options of the mount apart from those of the filesystem, which is read-only once the kernel
+// remounted it after errors even where it is bind mounted read-write.
+type MountInfo struct {
+	// DeviceNumber is the major:minor number of the filesystem's device.
+	DeviceNumber string
+	Device       string
+	MountPoint   string
+	FSType       string
+	MountOptions []string
+	SuperOptions []string
+}
+
+func (c *ProcfsRepository) GetMountInfo() ([]MountInfo, error) {
+	file, err := os.Open(c.path("proc", "self", "mountinfo"))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var mounts []MountInfo
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+
+		// The optional fields before the separator vary in number.
+		separator := -1
+		for i := 6; i < len(fields); i++ {
+			if fields[i] == "-" {
+				separator = i
+				break
+			}
+		}
+		if separator == -1 || separator+3 >= len(fields) {
+			continue
+		}
+
+		mounts = append(mounts, MountInfo{
+			DeviceNumber: fields[2],
+			Device:       unescapeMountField(fields[separator+2]),
+			MountPoint:   unescapeMountField(fields[4]),
+			FSType:       fields[separator+1],
+			MountOptions: strings.Split(fields[5], ","),
+			SuperOptions: strings.Split(fields[separator+3], ","),
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return mounts, nil
+}
+
+// FileSystemErrors holds the error count and lifetime writes ext4 keeps in its superblock.
+type FileSystemErrors struct {
+	Errors         uint64
+	LifetimeWrites uint64
+}
+
+// GetFileSystemErrors returns the error count of an ext4 filesystem, the only kind exposing it in sysfs.
+func (c *ProcfsRepository) GetFileSystemErrors(mount MountInfo) (*FileSystemErrors, error) {
+	// Devices such as /dev/root do not name the block device, its number does.
+	name := filepath.Base(mount.Device)
+	if link, err := os.Readlink(c.path("sys", "dev", "block", mount.DeviceNumber)); err == nil {
+		name = filepath.Base(link)
+	}
+
+	errorsCount, err := c.readUint("sys", "fs", "ext4", name, "errors_count")
+	if err != nil {
+		return nil, err
+	}
+
+	lifetimeWrites, _ := c.readUint("sys", "fs", "ext4", name, "lifetime_write_kbytes")
+
+	return &FileSystemErrors{Errors: errorsCount, LifetimeWrites: lifetimeWrites * 1024}, nil
+}
+
+// TemperatureSensor is a thermal zone or a temperature input of a hwmon device.
+type TemperatureSensor struct {
+	// Source is either thermal or hwmon.
+	Source string
+	// Name is the sysfs name, e.g. thermal_zone0 or hwmon1/temp2.
+	Name string
+	// Type is the thermal zone type or the hwmon device name, e.g. x86_pkg_temp or coretemp.
+	Type string
+	// Label describes hwmon inputs, e.g. Package id 0 or Composite.
+	Label       string
+	Temperature float64
+	TripPoints  []TripPoint
+}
+
+type TripPoint struct {
+	Type        string
+	Temperature float64
+}
+
+// Trip points of hwmon inputs, by the suffix of their sysfs file.
+var hwmonTripPoints = []string{"max", "crit", "emergency"}
+
+var hwmonTemperatureInput = regexp.MustCompile(`^temp(\d+)_input$`)
+
+// GetTemperatureSensors returns every thermal zone and hwmon temperature input which can be read.
+func (c *ProcfsRepository) GetTemperatureSensors() ([]TemperatureSensor, error) {
+	var sensors []TemperatureSensor
+
+	zones, err := c.numberedEntries(c.path("sys", "class", "thermal"), "thermal_zone")
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	for _, zone := range zones {
+		temperature, err := c.readTemperature("sys", "class", "thermal", zone, "temp")
+		if err != nil {
+			continue
+		}
+
+		zoneType, _ := c.readString("sys", "class", "thermal", zone, "type")
+		sensor := TemperatureSensor{Source: "thermal", Name: zone, Type: zoneType, Temperature: temperature}
+
+		for i := 0; ; i++ {
+			tripType, err := c.readString("sys", "class", "thermal", zone, fmt.Sprintf("trip_point_%d_type", i))
+			if err != nil {
+				break
+			}
+			tripTemperature, err := c.readTemperature("sys", "class", "thermal", zone, fmt.Sprintf("trip_point_%d_temp", i))
+			if err != nil {
+				continue
+			}
+			sensor.TripPoints = append(sensor.TripPoints, TripPoint{Type: tripType, Temperature: tripTemperature})
+		}
+
+		sensors = append(sensors, sensor)
+	}
+
+	devices, err := c.numberedEntries(c.path("sys", "class", "hwmon"), "hwmon")
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	for _, device := range devices {
+		sensors = append(sensors, c.hwmonTemperatureSensors(device)...)
+	}
+
+	return sensors, nil
+}
+
+func (c *ProcfsRepository) hwmonTemperatureSensors(device string) []TemperatureSensor {
+	entries, err := os.ReadDir(c.path("sys", "class", "hwmon", device))
+	if err != nil {
+		return nil
+	}
+
+	var inputs []int
+	for _, entry := range entries {
+		if match := hwmonTemperatureInput.FindStringSubmatch(entry.Name()); match != nil {
+			index, _ := strconv.Atoi(match[1])
+			inputs = append(inputs, index)
+		}
+	}
+	sort.Ints(inputs)
+
+	deviceName, _ := c.readString("sys", "class", "hwmon", device, "name")
+
+	var sensors []TemperatureSensor
+	for _, index := range inputs {
+		prefix := fmt.Sprintf("temp%d", index)
+
+		temperature, err := c.readTemperature("sys", "class", "hwmon", device, prefix+"_input")
+		if err != nil {
+			continue
+		}
+
+		label, _ := c.readString("sys", "class", "hwmon", device, prefix+"_label")
+		sensor := TemperatureSensor{
+			Source:      "hwmon",
+			Name:        device + "/" + prefix,
+			Type:        deviceName,
+			Label:       label,
+			Temperature: temperature,
+		}
+
+		for _, tripType := range hwmonTripPoints {
+			if tripTemperature, err := c.readTemperature("sys", "class", "hwmon", device, prefix+"_"+tripType); err == nil {
+				sensor.TripPoints = append(sensor.TripPoints, TripPoint{Type: tripType, Temperature: tripTemperature})
+			}
+		}
+
+		sensors = append(sensors, sensor)
+	}
+
+	return sensors
+}
+
+// readTemperature reads a temperature in millidegrees Celsius and returns it in degrees.
+func (c *ProcfsRepository) readTemperature(elem ...string) (float64, error) {
+	str, err := c.readString(elem...)
+	if err != nil {
+		return 0, err
+	}
+
+	milliDegrees, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Error parsing temperature: %w", err)
+	}
+
+	return float64(milliDegrees) / 1000, nil
+}
+
+// numberedEntries lists the entries of dir named prefix followed by a number, in numeric order.
+func (c *ProcfsRepository) numberedEntries(dir string, prefix string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	numbers := map[string]int{}
+	var names []string
+	for _, entry := range entries {
+		number, err := strconv.Atoi(strings.TrimPrefix(entry.Name(), prefix))
+		if err != nil || !strings.HasPrefix(entry.Name(), prefix) {
+			continue
+		}
+		numbers[entry.Name()] = number
+		names = append(names, entry.Name())
+	}
+
+	sort.Slice(names, func(a, b int) bool {
+		return numbers[names[a]] < numbers[names[b]]
+	})
+
+	return names, nil
+}
+
+func (c *ProcfsRepository) GetNetworkInterfaces() ([]string, error) {
+	entries, err := os.ReadDir(c.path("sys", "class", "net"))
+	if err != nil {
+		return nil, err
+	}
+
+	var interfaces []string
+	for _, entry := range entries {
+		interfaces = append(interfaces, entry.Name())
+	}
+
+	return interfaces, nil
+}
+
+// GetInterfaceStatistics reads all counters of the interface's statistics, e.g. rx_bytes or tx_dropped.
+func (c *ProcfsRepository) GetInterfaceStatistics(interfaceName string) (map[string]uint64, error) {
+	entries, err := os.ReadDir(c.path("sys", "class", "net", interfaceName, "statistics"))
+	if err != nil {
+		return nil, err
+	}
+
+	statistics := map[string]uint64{}
+	for _, entry := range entries {
+		value, err := c.readUint("sys", "class", "net", interfaceName, "statistics", entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("Error reading %s of interface %s: %w", entry.Name(), interfaceName, err)
+		}
+		statistics[entry.Name()] = value
+	}
+
+	return statistics, nil
+}
+
+// InterfaceInfo describes the link of a network interface.
+type InterfaceInfo struct {
+	OperState string
+	MTU       int
+	// Speed is in Mbit/s, 0 when unknown, e.g. for wireless or disconnected interfaces.
+	Speed  int
+	Duplex string
+	MAC    string
+	// Virtual interfaces such as lo, veth pairs or bridges have no device backing them.
+	Virtual bool
+}
+
+func (c *ProcfsRepository) GetInterfaceInfo(interfaceName string) (*InterfaceInfo, error) {
+	operState, err := c.readString("sys", "class", "net", interfaceName, "operstate")
+	if err != nil {
+		return nil, err
+	}
+
+	info := &InterfaceInfo{OperState: operState}
+
+	if mtu, err := c.readUint("sys", "class", "net", interfaceName, "mtu"); err == nil {
+		info.MTU = int(mtu)
+	}
+	// Reading the speed and duplex fails on interfaces which are down.
+	if speed, err := c.readString("sys", "class", "net", interfaceName, "speed"); err == nil {
+		if value, err := strconv.Atoi(speed); err == nil && value > 0 {
+			info.Speed = value
+		}
+	}
+	if duplex, err := c.readString("sys", "class", "net", interfaceName, "duplex"); err == nil && duplex != "unknown" {
+		info.Duplex = duplex
+	}
+	if mac, err := c.readString("sys", "class", "net", interfaceName, "address"); err == nil && mac != "00:00:00:00:00:00" {
+		info.MAC = mac
+	}
+	if _, err := os.Stat(c.path("sys", "devices", "virtual", "net", interfaceName)); err == nil {
+		info.Virtual = true
+	}
+
+	return info, nil
+}
+
+// GetInterfaceAddresses returns the IP addresses assigned to the interface in CIDR notation.
+// They are not exposed in procfs for IPv4, so they are read over netlink instead.
+func (c *ProcfsRepository) GetInterfaceAddresses(interfaceName string) ([]string, error) {
+	iface, err := net.InterfaceByName(interfaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+
+	var addresses []string
+	for _, addr := range addrs {
+		addresses = append(addresses, addr.String())
+	}
+
+	return addresses, nil
+}
+
+// GetDefaultRouteInterface returns the interface of the IPv4 default route, or of the IPv6 one without it.
+func (c *ProcfsRepository) GetDefaultRouteInterface() (string, error) {
+	if route, err := c.readString("proc", "net", "route"); err == nil {
+		for _, line := range strings.Split(route, "\n")[1:] {
+			fields := strings.Fields(line)
+			if len(fields) >= 8 && fields[1] == "00000000" && fields[7] == "00000000" {
+				return fields[0], nil
+			}
+		}
+	}
+
+	route, err := c.readString("proc", "net", "ipv6_route")
+	if err != nil {
+		return "", err
+	}
+
+	for _, line := range strings.Split(route, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 10 && fields[0] == strings.Repeat("0", 32) && fields[1] == "00" && fields[9] != "lo" {
+			return fields[9], nil
+		}
+	}
+
+	return "", errors.New("No default route")
+}
+
+// Process holds what /proc/[pid] tells about a process.
+type Process struct {
+	PID  int
+	Name string
+	// Command is empty for kernel threads.
+	Command []string
+	UID     int
+	// RSS is the resident memory in bytes.
+	RSS uint64
+	// CPUTime is the user and system time spent, in USER_HZ units.
+	CPUTime uint64
+	// StartTime tells a process apart from an earlier one with the same pid.
+	StartTime uint64
+}
+
+// GetProcesses returns the processes visible in procfs. Processes exiting while being read are left out.
+func (c *ProcfsRepository) GetProcesses() ([]Process, error) {
+	entries, err := os.ReadDir(c.path("proc"))
+	if err != nil {
+		return nil, err
+	}
+
+	var processes []Process
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		process, err := c.readProcess(pid)
+		if err != nil {
+			c.Logger.Debugf("Failed to read process %d: %v", pid, err)
+			continue
+		}
+		processes = append(processes, *process)
+	}
+
+	return processes, nil
+}
+
+func (c *ProcfsRepository) readProcess(pid int) (*Process, error) {
+	process := &Process{PID: pid, UID: -1}
+	dir := strconv.Itoa(pid)
+
+	stat, err := c.readString("proc", dir, "stat")
+	if err != nil {
+		return nil, err
+	}
+
+	// The name in parentheses may contain spaces and parentheses itself.
+	nameEnd := strings.LastIndex(stat, ")")
+	if nameEnd == -1 {
+		return nil, fmt.Errorf("Unexpected stat format: %q", stat)
+	}
+	fields := strings.Fields(stat[nameEnd+1:])
+	if len(fields) < 20 {
+		return nil, fmt.Errorf("Unexpected stat format: %q", stat)
+	}
+
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	process.CPUTime = utime + stime
+	process.StartTime, _ = strconv.ParseUint(fields[19], 10, 64)
+
+	status, err := c.readString("proc", dir, "status")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range strings.Split(status, "\n") {
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "Name":
+			process.Name = value
+		case "Uid":
+			if uids := strings.Fields(value); len(uids) > 0 {
+				process.UID, _ = strconv.Atoi(uids[0])
+			}
+		case "VmRSS":
+			if kb := strings.Fields(value); len(kb) > 0 {
+				rss, _ := strconv.ParseUint(kb[0], 10, 64)
+				process.RSS = rss * 1024
+			}
+		}
+	}
+
+	cmdline, err := os.ReadFile(c.path("proc", dir, "cmdline"))
+	if err != nil {
+		return nil, err
+	}
+	if args := strings.TrimRight(string(cmdline), "\x00"); args != "" {
+		process.Command = strings.Split(args, "\x00")
+	}
+
+	return process, nil
+}
+
+// GetUserNames maps user ids to names from /etc/passwd.
+func (c *ProcfsRepository) GetUserNames() (map[int]string, error) {
+	passwd, err := c.readString("etc", "passwd")
+	if err != nil {
+		return nil, err
+	}
+
+	users := map[int]string{}
+	for _, line := range strings.Split(passwd, "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) < 3 {
+			continue
+		}
+		if uid, err := strconv.Atoi(fields[2]); err == nil {
+			users[uid] = fields[0]
+		}
+	}
+
+	return users, nil
+}
+
+// PressureStall holds the share of time tasks were stalled on a resource, averaged over
+// 10, 60 and 300 seconds, and the total stall time in microseconds.
+type PressureStall struct {
+	Avg10  float64
+	Avg60  float64
+	Avg300 float64
+	Total  uint64
+}
+
+// Pressure holds the stalls where some or all non-idle tasks waited for a resource.
+type Pressure struct {
+	Some *PressureStall
+	// Full is not reported for the CPU before Linux 5.13.
+	Full *PressureStall
+}
+
+// GetPressure reads the pressure stall information of cpu, memory or io. It fails on
+// kernels built without PSI or booted with psi=0.
+func (c *ProcfsRepository) GetPressure(resource string) (*Pressure, error) {
+	content, err := c.readString("proc", "pressure", resource)
+	if err != nil {
+		return nil, err
+	}
+
+	pressure := &Pressure{}
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 5 {
+			continue
+		}
+
+		stall := &PressureStall{}
+		for _, field := range fields[1:] {
+			key, value, _ := strings.Cut(field, "=")
+			switch key {
+			case "avg10":
+				stall.Avg10, _ = strconv.ParseFloat(value, 64)
+			case "avg60":
+				stall.Avg60, _ = strconv.ParseFloat(value, 64)
+			case "avg300":
+				stall.Avg300, _ = strconv.ParseFloat(value, 64)
+			case "total":
+				stall.Total, _ = strconv.ParseUint(value, 10, 64)
+			}
+		}
+
+		switch fields[0] {
+		case "some":
+			pressure.Some = stall
+		case "full":
+			pressure.Full = stall
+		}
+	}
+
+	return pressure, nil
+}
+
+// GetVMStat returns the virtual memory counters of /proc/vmstat, e.g. pswpin or oom_kill.
+func (c *ProcfsRepository) GetVMStat() (map[string]uint64, error) {
+	content, err := c.readString("proc", "vmstat")
+	if err != nil {
+		return nil, err
+	}
+
+	vmStat := map[string]uint64{}
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if value, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			vmStat[fields[0]] = value
+		}
+	}
+
+	return vmStat, nil
+}
+
+// ZramDevice describes a compressed RAM disk, sizes are in bytes.
+type ZramDevice struct {
+	Name      string
+	Algorithm string
+	DiskSize  uint64
+	// OriginalSize is the size of the data stored, CompressedSize its size after compression
+	// and MemoryUsed the memory taken including fragmentation and metadata.
+	OriginalSize   uint64
+	CompressedSize uint64
+	MemoryUsed     uint64
+	// MemoryLimit is 0 when unlimited.
+	MemoryLimit uint64
+}
+
+// GetZramDevices returns the zram devices which are set up.
+func (c *ProcfsRepository) GetZramDevices() ([]ZramDevice, error) {
+	names, err := c.numberedEntries(c.path("sys", "block"), "zram")
+	if err != nil {
+		return nil, err
+	}
+
+	var devices []ZramDevice
+	for _, name := range names {
+		diskSize, err := c.readUint("sys", "block", name, "disksize")
+		if err != nil || diskSize == 0 {
+			continue
+		}
+
+		mmStat, err := c.readString("sys", "block", name, "mm_stat")
+		if err != nil {
+			return nil, err
+		}
+		fields := strings.Fields(mmStat)
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("Unexpected mm_stat format of %s: %q", name, mmStat)
+		}
+
+		var values [4]uint64
+		for i := range values {
+			if values[i], err = strconv.ParseUint(fields[i], 10, 64); err != nil {
+				return nil, fmt.Errorf("Error parsing mm_stat of %s: %w", name, err)
+			}
+		}
+
+		device := ZramDevice{
+			Name:           name,
+			DiskSize:       diskSize,
+			OriginalSize:   values[0],
+			CompressedSize: values[1],
+			MemoryUsed:     values[2],
+			MemoryLimit:    values[3],
+		}
+
+		// The algorithm in use is the one in brackets, e.g. lzo [lz4] zstd.
+		if algorithms, err := c.readString("sys", "block", name, "comp_algorithm"); err == nil {
+			for _, algorithm := range strings.Fields(algorithms) {
+				if strings.HasPrefix(algorithm, "[") {
+					device.Algorithm = strings.Trim(algorithm, "[]")
+				}
+			}
+		}
+
+		devices = append(devices, device)
+	}
+
+	return devices, nil
+}
+
+// ZswapParameters holds the settings of the compressed swap cache.
+type ZswapParameters struct {
+	Enabled        bool
+	Compressor     string
+	MaxPoolPercent int
+}
+
+// GetZswapParameters fails on kernels built without zswap.
+func (c *ProcfsRepository) GetZswapParameters() (*ZswapParameters, error) {
+	enabled, err := c.readString("sys", "module", "zswap", "parameters", "enabled")
+	if err != nil {
+		return nil, err
+	}
+
+	parameters := &ZswapParameters{Enabled: enabled == "Y" || enabled == "1"}
+	parameters.Compressor, _ = c.readString("sys", "module", "zswap", "parameters", "compressor")
+	if maxPoolPercent, err := c.readUint("sys", "module", "zswap", "parameters", "max_pool_percent"); err == nil {
+		parameters.MaxPoolPercent = int(maxPoolPercent)
+	}
+
+	return parameters, nil
+}
+
+func decodeCPUModel(model string) string {
+	cpuPartMap := map[string]string{
+		"0x810": "ARM810",
+		"0x920": "ARM920",
+		"0x922": "ARM922",
+		"0x926": "ARM926",
+		"0x940": "ARM940",
+		"0x946": "ARM946",
+		"0x966": "ARM966",
+		"0xa20": "ARM1020",
+		"0xa22": "ARM1022",
+		"0xa26": "ARM1026",
+		"0xb02": "ARM11 MPCore",
+		"0xb36": "ARM1136",
+		"0xb56": "ARM1156",
+		"0xb76": "ARM1176",
+		"0xc05": "Cortex-A5",
+		"0xc07": "Cortex-A7",
+		"0xc08": "Cortex-A8",
+		"0xc09": "Cortex-A9",
+		"0xc0d": "Cortex-A12",
+		"0xc0f": "Cortex-A15",
+		"0xc0e": "Cortex-A17",
+		"0xc14": "Cortex-R4",
+		"0xc15": "Cortex-R5",
+		"0xc17": "Cortex-R7",
+		"0xc18": "Cortex-R8",
+		"0xc20": "Cortex-M0",
+		"0xc21": "Cortex-M1",
+		"0xc23": "Cortex-M3",
+		"0xc24": "Cortex-M4",
+		"0xc27": "Cortex-M7",
+		"0xc60": "Cortex-M0+",
+		"0xd01": "Cortex-A32",
+		"0xd03": "Cortex-A53",
+		"0xd04": "Cortex-A35",
+		"0xd05": "Cortex-A55",
+		"0xd07": "Cortex-A57",
+		"0xd08": "Cortex-A72",
+		"0xd09": "Cortex-A73",
+		"0xd0a": "Cortex-A75",
+		"0xd13": "Cortex-R52",
+		"0xd20": "Cortex-M23",
+		"0xd21": "Cortex-M33",
+		"0x516": "ThunderX2",
+		"0xa10": "SA110",
+		"0xa11": "SA1100",
+		"0x0a0": "ThunderX",
+		"0x0a1": "ThunderX 88XX",
+		"0x0a2": "ThunderX 81XX",
+		"0x0a3": "ThunderX 83XX",
+		"0x0af": "ThunderX2 99xx",
+		"0x000": "X-Gene",
+		"0x00f": "Scorpion",
+		"0x02d": "Scorpion",
+		"0x04d": "Krait",
+		"0x06f": "Krait",
+		"0x201": "Kryo",
+		"0x205": "Kryo",
+		"0x211": "Kryo",
+		"0x800": "Falkor V1/Kryo",
+		"0x801": "Kryo V2",
+		"0xc00": "Falkor",
+		"0xc01": "Saphira",
+		"0x001": "exynos-m1",
+		"0x003": "Denver 2",
+		"0x131": "Feroceon 88FR131",
+		"0x581": "PJ4/PJ4b",
+		"0x584": "PJ4B-MP",
+		"0x200": "i80200",
+		"0x210": "PXA250A",
+		"0x212": "PXA210A",
+		"0x242": "i80321-400",
+		"0x243": "i80321-600",
+		"0x290": "PXA250B/PXA26x",
+		"0x292": "PXA210B",
+		"0x2c2": "i80321-400-B0",
+		"0x2c3": "i80321-600-B0",
+		"0x2d0": "PXA250C/PXA255/PXA26x",
+		"0x2d2": "PXA210C",
+		"0x411": "PXA27x",
+		"0x41c": "IPX425-533",
+		"0x41d": "IPX425-400",
+		"0x41f": "IPX425-266",
+		"0x682": "PXA32x",
+		"0x683": "PXA930/PXA935",
+		"0x688": "PXA30x",
+		"0x689": "PXA31x",
+		"0xb11": "SA1110",
+		"0xc12": "IPX1200",
+	}
+
+	modelName, exists := cpuPartMap[model]
+	if !exists {
+		return model
+	}
+
+	return modelName
+}
@@ -0,0 +1,38 @@
+//go:build linux
+
+package procfsrepository
+
+import "syscall"
+
+func statfs(path string) (*FSStat, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return nil, err
+	}
+
+	blockSize := uint64(stat.Bsize)
+	return &FSStat{
+		Size:      stat.Blocks * blockSize,
+		Free:      stat.Bfree * blockSize,
+		Available: stat.Bavail * blockSize,
+		Files:     stat.Files,
+		FilesFree: stat.Ffree,
+	}, nil
+}
+
+func machine() (string, error) {
+	var uname syscall.Utsname
+	if err := syscall.Uname(&uname); err != nil {
+		return "", err
+	}
+
+	var machine []byte
+	for _, c := range uname.Machine {
+		if c == 0 {
+			break
+		}
+		machine = append(machine, byte(c))
+	}
+
+	return string(machine), nil
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package procfsrepository
+
+import (
+	"errors"
+	"runtime"
+)
+
+func statfs(path string) (*FSStat, error) {
+	return nil, errors.New("statfs is only supported on Linux")
+}
+
+func machine() (string, error) {
+	return runtime.GOARCH, nil
+}
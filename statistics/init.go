@@ -151,8 +151,8 @@ func (s *Statistics) SetLastSuccessfulConnection(lastConn time.Time) {
 }
 
 func (s *Statistics) IncrementJobsProcessedCount() {
-	s.lock.RLock()
-	defer s.lock.RUnlock()
+	s.lock.Lock()
+	defer s.lock.Unlock()
 
 	s.jobsProcessedCount += 1
 }
@@ -24,6 +24,26 @@ type CPU struct {
 	Load         float64 `json:"load"`
 	CPUMHz       string  `json:"cpu_mhz"`
 	Temperature  float64 `json:"temp"`
+	CoreCount    int     `json:"core_count"`
+	// Usage breaks Load down by state, both cover the time since the previous observation.
+	Usage       *CPUUsage    `json:"usage"`
+	CoreLoads   []float64    `json:"core_loads"`
+	LoadAverage *LoadAverage `json:"load_average"`
+}
+
+// CPUUsage holds the percentage of time spent in each state.
+type CPUUsage struct {
+	User   float64 `json:"user"`
+	System float64 `json:"system"`
+	IOWait float64 `json:"iowait"`
+	Steal  float64 `json:"steal"`
+	Idle   float64 `json:"idle"`
+}
+
+type LoadAverage struct {
+	One     float64 `json:"one"`
+	Five    float64 `json:"five"`
+	Fifteen float64 `json:"fifteen"`
 }
 
 type Storage struct {
@@ -33,29 +53,226 @@ type Storage struct {
 	MountedOn     string `json:"mounted_on"`
 	Name          string `json:"name"`
 	Available     string `json:"available"`
+	FSType        string `json:"fstype"`
+	// The byte counts are exact, unlike the df -h style strings above.
+	SizeBytes      uint64 `json:"size_bytes"`
+	UsedBytes      uint64 `json:"used_bytes"`
+	AvailableBytes uint64 `json:"available_bytes"`
+	// Filesystems such as vfat or btrfs have no fixed number of inodes and report 0.
+	Inodes     uint64 `json:"inodes"`
+	InodesUsed uint64 `json:"inodes_used"`
 }
 
 type Environment struct {
-	Memory   *Memory   `json:"memory"`
-	CPU      *CPU      `json:"cpu"`
-	Storage  []Storage `json:"storage"`
-	Network  *Network  `json:"network"`
-	BootTime string    `json:"boot_time"`
+	Memory        *Memory             `json:"memory"`
+	CPU           *CPU                `json:"cpu"`
+	Storage       []Storage           `json:"storage"`
+	Network       *Network            `json:"network"`
+	BootTime      string              `json:"boot_time"`
+	Temperatures  []TemperatureSensor `json:"temperatures"`
+	Disks         []Disk              `json:"disks"`
+	StorageHealth *StorageHealth      `json:"storage_health"`
+	Processes     *Processes          `json:"processes"`
+	Pressure      *Pressure           `json:"pressure"`
+	VirtualMemory *VirtualMemory      `json:"virtual_memory"`
+	Sampling      *Sampling           `json:"sampling,omitempty"`
+}
+
+// Sampling summarizes the samples taken since the previous observation, catching spikes
+// a single snapshot per observation misses. Metrics which could not be sampled are missing.
+type Sampling struct {
+	// Interval is the time between samples in seconds.
+	Interval float64           `json:"interval"`
+	Count    int               `json:"count"`
+	CPULoad  *SampleStatistics `json:"cpu_load"`
+	// MemoryUsed is the percentage of memory which is not available.
+	MemoryUsed              *SampleStatistics `json:"memory_used"`
+	CPUTemperature          *SampleStatistics `json:"cpu_temp"`
+	DiskReadBytesPerSecond  *SampleStatistics `json:"disk_read_bytes_per_second"`
+	DiskWriteBytesPerSecond *SampleStatistics `json:"disk_write_bytes_per_second"`
+}
+
+type SampleStatistics struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+	Avg float64 `json:"avg"`
+	P95 float64 `json:"p95"`
+}
+
+// Pressure holds the pressure stall information of the CPU, memory and I/O, missing
+// on kernels without PSI.
+type Pressure struct {
+	CPU    *ResourcePressure `json:"cpu"`
+	Memory *ResourcePressure `json:"memory"`
+	IO     *ResourcePressure `json:"io"`
+}
+
+// ResourcePressure holds the stalls where some or all non-idle tasks waited for the resource.
+type ResourcePressure struct {
+	Some *PressureStall `json:"some"`
+	Full *PressureStall `json:"full"`
+}
+
+// PressureStall holds the percentage of time tasks were stalled, averaged over 10, 60 and 300 seconds.
+type PressureStall struct {
+	Avg10  float64 `json:"avg10"`
+	Avg60  float64 `json:"avg60"`
+	Avg300 float64 `json:"avg300"`
+	// Total is the stall time since boot in microseconds.
+	Total uint64 `json:"total"`
+}
+
+type VirtualMemory struct {
+	// OOMKills counts the processes killed for lack of memory since boot.
+	OOMKills uint64 `json:"oom_kills"`
+	// The swap rates cover the time since the previous observation, they are missing on the first one.
+	SwapInPagesPerSecond  *float64 `json:"swap_in_pages_per_second"`
+	SwapOutPagesPerSecond *float64 `json:"swap_out_pages_per_second"`
+	Zram                  []Zram   `json:"zram"`
+	Zswap                 *Zswap   `json:"zswap"`
+}
+
+// Zram describes a compressed RAM disk, usually used for swap. Sizes are in bytes.
+type Zram struct {
+	Name           string `json:"name"`
+	Algorithm      string `json:"algorithm"`
+	DiskSize       uint64 `json:"disk_size"`
+	OriginalSize   uint64 `json:"original_size"`
+	CompressedSize uint64 `json:"compressed_size"`
+	MemoryUsed     uint64 `json:"memory_used"`
+	MemoryLimit    uint64 `json:"memory_limit"`
+}
+
+// Zswap describes the compressed swap cache, the sizes are missing before Linux 5.19.
+type Zswap struct {
+	Enabled        bool    `json:"enabled"`
+	Compressor     string  `json:"compressor"`
+	MaxPoolPercent int     `json:"max_pool_percent"`
+	PoolSize       *uint64 `json:"pool_size"`
+	StoredSize     *uint64 `json:"stored_size"`
+}
+
+// Processes lists the processes using the most memory and the most CPU.
+type Processes struct {
+	TopMemory []Process `json:"top_memory"`
+	TopCPU    []Process `json:"top_cpu"`
+}
+
+type Process struct {
+	PID     int    `json:"pid"`
+	Name    string `json:"name"`
+	Command string `json:"command"`
+	User    string `json:"user"`
+	RSS     uint64 `json:"rss"`
+	// CPU is the percentage of one core used since the previous observation.
+	CPU float64 `json:"cpu"`
+}
+
+// StorageHealth reports signs of worn or failing storage.
+type StorageHealth struct {
+	Disks       []DiskHealth       `json:"disks"`
+	FileSystems []FileSystemHealth `json:"filesystems"`
+}
+
+type DiskHealth struct {
+	Name string `json:"name"`
+	// Type is mmc, sd, sd-combo or nvme.
+	Type string `json:"type"`
+	// LifeTimeUsed estimates the used life of an eMMC's SLC (a) and MLC (b) areas as the upper bound
+	// of the reported 10% step, above 100 once the estimated life is exceeded.
+	LifeTimeUsedA *int `json:"life_time_used_a,omitempty"`
+	LifeTimeUsedB *int `json:"life_time_used_b,omitempty"`
+	// PreEOL is the state of an eMMC's reserved blocks: normal, warning or urgent.
+	PreEOL           string `json:"pre_eol,omitempty"`
+	State            string `json:"state,omitempty"`
+	TemperatureAlarm bool   `json:"temperature_alarm"`
+}
+
+type FileSystemHealth struct {
+	Device    string `json:"device"`
+	MountedOn string `json:"mounted_on"`
+	FSType    string `json:"fstype"`
+	// ReadOnly is set when the filesystem itself is read-only, e.g. remounted by the kernel after errors.
+	ReadOnly bool `json:"read_only"`
+	// Errors and BytesWritten are kept by ext4 only.
+	Errors       *uint64 `json:"errors"`
+	BytesWritten *uint64 `json:"bytes_written,omitempty"`
+}
+
+// Disk holds the I/O counters of a block device since boot. Times are in milliseconds.
+type Disk struct {
+	Name         string `json:"name"`
+	Model        string `json:"model,omitempty"`
+	Size         uint64 `json:"size"`
+	Rotational   bool   `json:"rotational"`
+	Removable    bool   `json:"removable"`
+	Reads        uint64 `json:"reads"`
+	Writes       uint64 `json:"writes"`
+	BytesRead    uint64 `json:"bytes_read"`
+	BytesWritten uint64 `json:"bytes_written"`
+	ReadTime     uint64 `json:"read_time"`
+	WriteTime    uint64 `json:"write_time"`
+	IOTime       uint64 `json:"io_time"`
+	InFlight     uint64 `json:"in_flight"`
+	// Rates covers the time since the previous observation, it is missing for disks seen for the first time.
+	Rates *DiskRates `json:"rates"`
+}
+
+type DiskRates struct {
+	ReadsPerSecond        float64 `json:"reads_per_second"`
+	WritesPerSecond       float64 `json:"writes_per_second"`
+	BytesReadPerSecond    float64 `json:"bytes_read_per_second"`
+	BytesWrittenPerSecond float64 `json:"bytes_written_per_second"`
+	// Utilization is the percentage of time the disk was busy.
+	Utilization float64 `json:"utilization"`
+	// QueueDepth is the average number of requests in flight.
+	QueueDepth float64 `json:"queue_depth"`
+}
+
+// TemperatureSensor is a thermal zone or a hwmon temperature input.
+type TemperatureSensor struct {
+	Source      string      `json:"source"`
+	Name        string      `json:"name"`
+	Type        string      `json:"type"`
+	Label       string      `json:"label,omitempty"`
+	Temperature float64     `json:"temp"`
+	TripPoints  []TripPoint `json:"trip_points,omitempty"`
+}
+
+type TripPoint struct {
+	Type        string  `json:"type"`
+	Temperature float64 `json:"temp"`
 }
 
 type Network struct {
-	Interfaces []NetworkInterface `json:"interfaces"`
+	Interfaces       []NetworkInterface `json:"interfaces"`
+	DefaultInterface string             `json:"default_interface"`
 }
 
 type NetworkInterface struct {
-	Name string                `json:"name"`
-	Rx   *NetworkInterfaceData `json:"rx"`
-	Tx   *NetworkInterfaceData `json:"tx"`
+	Name      string                `json:"name"`
+	Rx        *NetworkInterfaceData `json:"rx"`
+	Tx        *NetworkInterfaceData `json:"tx"`
+	OperState string                `json:"operstate"`
+	MTU       int                   `json:"mtu"`
+	// Speed is in Mbit/s, missing when unknown.
+	Speed  *int     `json:"speed"`
+	Duplex string   `json:"duplex,omitempty"`
+	MAC    string   `json:"mac,omitempty"`
+	IPv4   []string `json:"ipv4"`
+	IPv6   []string `json:"ipv6"`
+	// Virtual interfaces such as lo, veth pairs or the docker0 and hassio bridges.
+	Virtual bool `json:"virtual"`
 }
 
 type NetworkInterfaceData struct {
 	Bytes   int `json:"bytes"`
 	Packets int `json:"packets"`
+	Errors  int `json:"errors"`
+	Dropped int `json:"dropped"`
+	// The rates cover the time since the previous observation, they are missing for interfaces seen for the first time.
+	BytesPerSecond   *float64 `json:"bytes_per_second"`
+	PacketsPerSecond *float64 `json:"packets_per_second"`
 }
 
 type DockerContainer struct {
@@ -117,6 +334,66 @@ type GenericJob struct {
 	ID                   string      `json:"id"`
 	Type                 string      `json:"type"`
 	Context              interface{} `json:"context"`
+	ExpiresAt            string      `json:"expires_at"`
+	Signature            string      `json:"signature"`
+	NotBefore            string      `json:"not_before"`
+	NotAfter             string      `json:"not_after"`
+}
+
+const (
+	JobStatusCompleted = "completed"
+	JobStatusRejected  = "rejected"
+	JobStatusExpired   = "expired"
+	JobStatusFailed    = "failed"
+	// JobStatusUnknown is reported for jobs interrupted while running, which may or may not have taken effect.
+	JobStatusUnknown = "unknown"
+)
+
+// JobResult is reported to the backend when a job is dequeued.
+type JobResult struct {
+	Status string      `json:"status"`
+	Reason string      `json:"reason,omitempty"`
+	Data   interface{} `json:"data,omitempty"`
+}
+
+// JobProgress is streamed to the backend while a long-running job is in progress.
+type JobProgress struct {
+	Stage    string  `json:"stage"`
+	Progress float64 `json:"progress"`
+}
+
+type SupervisorJob struct {
+	Name      string               `json:"name"`
+	Reference *string              `json:"reference"`
+	UUID      string               `json:"uuid"`
+	Progress  float64              `json:"progress"`
+	Stage     *string              `json:"stage"`
+	Done      bool                 `json:"done"`
+	ChildJobs []SupervisorJob      `json:"child_jobs"`
+	Errors    []SupervisorJobError `json:"errors"`
+}
+
+type SupervisorJobError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+type SupervisorJobsResponse struct {
+	Data struct {
+		Jobs []SupervisorJob `json:"jobs"`
+	} `json:"data"`
+}
+
+// UpdateInfo holds the version fields shared by the supervisor core, os, supervisor and add-on info endpoints.
+type UpdateInfo struct {
+	Version         string `json:"version"`
+	VersionLatest   string `json:"version_latest"`
+	UpdateAvailable bool   `json:"update_available"`
+	State           string `json:"state,omitempty"`
+}
+
+type UpdateInfoResponse struct {
+	Data UpdateInfo `json:"data"`
 }
 
 type JobsResponse struct {
@@ -205,6 +482,16 @@ type Scene struct {
 	FriendlyName string    `json:"friendly_name" yaml:"friendly_name"`
 }
 
+type CoreConfig struct {
+	Version int            `json:"version"`
+	Key     string         `json:"key"`
+	Data    CoreConfigData `json:"data"`
+}
+
+type CoreConfigData struct {
+	TimeZone string `json:"time_zone"`
+}
+
 type EntityRegistry struct {
 	Version      int                     `json:"version"`
 	MinorVersion int                     `json:"minor_version"`
@@ -217,12 +504,33 @@ type EntityRegistryDataClass struct {
 }
 
 type EntityRegistryEntity struct {
+	ConfigEntryID       *string `json:"config_entry_id"`
 	DeviceClass         *string `json:"device_class"`
 	DeviceID            *string `json:"device_id"`
 	EntityID            string  `json:"entity_id"`
 	ID                  string  `json:"id"`
 	Name                *string `json:"name"`
 	OriginalDeviceClass *string `json:"original_device_class"`
+	Platform            string  `json:"platform"`
+}
+
+type ConfigEntries struct {
+	Version      int                    `json:"version"`
+	MinorVersion int                    `json:"minor_version"`
+	Key          string                 `json:"key"`
+	Data         ConfigEntriesDataClass `json:"data"`
+}
+
+type ConfigEntriesDataClass struct {
+	Entries []ConfigEntry `json:"entries"`
+}
+
+// ConfigEntry holds the identifying fields of a config entry. Its data and options
+// are left out, as they contain credentials.
+type ConfigEntry struct {
+	EntryID string `json:"entry_id"`
+	Domain  string `json:"domain"`
+	Title   string `json:"title"`
 }
 
 type DeviceRegistry struct {
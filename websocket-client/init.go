@@ -3,7 +3,10 @@ package websocketclient
 import (
 	"crypto/tls"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -97,6 +100,124 @@ func (client *WebSocketClient) FetchNotifications(accessToken string) (*WSAPINot
 	}
 }
 
+const WebsocketMessageTypeAuthInvalid = "auth_invalid"
+const WebsocketMessageTypeResult = "result"
+
+const callServiceTimeout = 10 * time.Minute
+
+// authTimeout bounds the auth handshake, so an endpoint which never replies does not block forever.
+const authTimeout = 30 * time.Second
+
+// ErrServiceCallUnknown is wrapped by errors which occur once a service call was sent,
+// when Home Assistant may or may not have performed it.
+var ErrServiceCallUnknown = errors.New("service call outcome is unknown")
+
+type WebsocketMessageCallService struct {
+	Id             int                    `json:"id"`
+	Type           string                 `json:"type"`
+	Domain         string                 `json:"domain"`
+	Service        string                 `json:"service"`
+	ServiceData    map[string]interface{} `json:"service_data,omitempty"`
+	Target         map[string]interface{} `json:"target,omitempty"`
+	ReturnResponse bool                   `json:"return_response,omitempty"`
+}
+
+type WSAPIResult struct {
+	ID      int             `json:"id"`
+	Type    string          `json:"type"`
+	Success bool            `json:"success"`
+	Result  json.RawMessage `json:"result"`
+	Error   *WSAPIError     `json:"error"`
+}
+
+// WSAPIError is an error returned by Home Assistant for a command.
+type WSAPIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *WSAPIError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// authenticate completes the Home Assistant websocket auth handshake on an open connection.
+func (client *WebSocketClient) authenticate(accessToken string) error {
+	client.Conn.SetReadDeadline(time.Now().Add(authTimeout))
+	defer client.Conn.SetReadDeadline(time.Time{})
+
+	for {
+		_, message, err := client.Conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		var response WSAPIResult
+		if err := json.Unmarshal(message, &response); err != nil {
+			return err
+		}
+
+		switch response.Type {
+		case WebsocketMessageTypeAuthRequired:
+			if err := client.Conn.WriteJSON(WebsocketMessageAuthBody{Type: "auth", AccessToken: accessToken}); err != nil {
+				return err
+			}
+		case WebsocketMessageTypeAuthOK:
+			return nil
+		case WebsocketMessageTypeAuthInvalid:
+			return errors.New("Home Assistant rejected the access token")
+		}
+	}
+}
+
+// CallService calls a Home Assistant service and returns the result of the call,
+// which includes the service response if it was requested.
+func (client *WebSocketClient) CallService(accessToken string, call WebsocketMessageCallService) (json.RawMessage, error) {
+	err := client.Connect()
+	if err != nil {
+		return nil, err
+	}
+	defer client.Conn.Close()
+
+	if err := client.authenticate(accessToken); err != nil {
+		return nil, err
+	}
+
+	// Services such as recorder.purge may take a while, but should not hang forever.
+	client.Conn.SetReadDeadline(time.Now().Add(callServiceTimeout))
+
+	call.Id = 1
+	call.Type = "call_service"
+	if err := client.Conn.WriteJSON(call); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrServiceCallUnknown, err)
+	}
+
+	for {
+		_, message, err := client.Conn.ReadMessage()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrServiceCallUnknown, err)
+		}
+
+		var response WSAPIResult
+		if err := json.Unmarshal(message, &response); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrServiceCallUnknown, err)
+		}
+
+		if response.Type != WebsocketMessageTypeResult || response.ID != call.Id {
+			continue
+		}
+
+		if !response.Success {
+			if response.Error != nil {
+				return nil, response.Error
+			}
+			// Home Assistant answered the call, so the failure is final even without details.
+			return nil, &WSAPIError{Code: "unknown_error", Message: "service call failed"}
+		}
+
+		return response.Result, nil
+	}
+}
+
 // func main() {
 // 	client := NewWebSocketClient("ws://192.168.1.24:8123/api/websocket")
 